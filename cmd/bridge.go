@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/spf13/cobra"
+
+	"github.com/endobit/wifire"
+)
+
+func newBridgeCmd(l *login) *cobra.Command {
+	var (
+		broker             string
+		username, password string
+		prefix             string
+	)
+
+	cmd := cobra.Command{
+		Use:   "bridge",
+		Short: "Republish the grill status to a local MQTT broker for Home Assistant",
+		Long: `Bridge republishes every grill status field to a local MQTT broker along
+with Home Assistant MQTT discovery messages so the sensors are created
+automatically.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			g, done, err := l.connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer done()
+
+			b := newBridge(prefix, g.Name(), g.Model())
+
+			opts := mqtt.NewClientOptions()
+			opts.AddBroker(broker)
+			opts.SetClientID("wifire-bridge-" + g.Name())
+			opts.SetUsername(username)
+			opts.SetPassword(password)
+			opts.OnConnect = b.onConnect
+
+			client := mqtt.NewClient(opts)
+			if t := client.Connect(); t.Wait() && t.Error() != nil {
+				return fmt.Errorf("cannot connect to %s: %w", broker, t.Error())
+			}
+			defer client.Disconnect(250)
+
+			ch := make(chan wifire.Status, 1)
+
+			if err := g.SubscribeStatus(ch); err != nil {
+				return err
+			}
+
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case s, ok := <-ch:
+					if !ok {
+						return wifire.ErrNotConnected
+					}
+
+					if s.Error != nil {
+						slog.Error("invalid status", "error", s.Error)
+						continue
+					}
+
+					if err := b.publish(ctx, client, s); err != nil {
+						slog.Error("cannot publish", "broker", broker, "error", err)
+					}
+				}
+			}
+		},
+	}
+
+	l.flags(cmd.Flags())
+	cmd.Flags().StringVar(&broker, "broker", "tcp://localhost:1883", "local MQTT broker URL")
+	cmd.Flags().StringVar(&username, "broker-username", "", "local MQTT broker username")
+	cmd.Flags().StringVar(&password, "broker-password", "", "local MQTT broker password")
+	cmd.Flags().StringVar(&prefix, "discovery-prefix", "homeassistant", "Home Assistant MQTT discovery prefix")
+
+	return &cmd
+}
+
+// sensor is a Status field published as a Home Assistant sensor.
+type sensor struct {
+	id          string
+	name        string
+	temperature bool // uses the Status Units
+	unit        string
+	value       func(wifire.Status) string
+}
+
+var sensors = []sensor{
+	{id: "ambient", name: "Ambient", temperature: true, value: func(s wifire.Status) string { return strconv.Itoa(s.Ambient) }},
+	{id: "grill", name: "Grill", temperature: true, value: func(s wifire.Status) string { return strconv.Itoa(s.Grill) }},
+	{id: "grill_set", name: "Grill Set", temperature: true, value: func(s wifire.Status) string { return strconv.Itoa(s.GrillSet) }},
+	{id: "probe", name: "Probe", temperature: true, value: func(s wifire.Status) string { return strconv.Itoa(s.Probe) }},
+	{id: "probe_set", name: "Probe Set", temperature: true, value: func(s wifire.Status) string { return strconv.Itoa(s.ProbeSet) }},
+	{id: "pellet_level", name: "Pellet Level", unit: "%", value: func(s wifire.Status) string { return strconv.Itoa(s.PelletLevel) }},
+	{id: "system_status", name: "Status", value: func(s wifire.Status) string { return s.SystemStatus.String() }},
+}
+
+// bridge publishes Status updates in the Home Assistant MQTT discovery
+// format.
+type bridge struct {
+	prefix string
+	grill  string
+	model  wifire.GrillModel
+
+	mutex     sync.Mutex
+	announced bool         // discovery configs have been published
+	units     wifire.Units // of the announced configs
+}
+
+func newBridge(prefix, grill string, model wifire.GrillModel) *bridge {
+	return &bridge{prefix: prefix, grill: grill, model: model}
+}
+
+// onConnect forces the discovery configs to be published again after a
+// (re)connect to the local broker.
+func (b *bridge) onConnect(_ mqtt.Client) {
+	b.mutex.Lock()
+	b.announced = false
+	b.mutex.Unlock()
+}
+
+func (b *bridge) topic(s sensor, kind string) string {
+	return b.prefix + "/sensor/" + b.grill + "/" + s.id + "/" + kind
+}
+
+// discoveryConfig is the Home Assistant MQTT discovery payload for a sensor.
+type discoveryConfig struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	StateTopic        string          `json:"state_topic"`
+	DeviceClass       string          `json:"device_class,omitempty"`
+	UnitOfMeasurement string          `json:"unit_of_measurement,omitempty"`
+	Device            discoveryDevice `json:"device"`
+}
+
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model,omitempty"`
+}
+
+func (b *bridge) config(s sensor, units wifire.Units) discoveryConfig {
+	c := discoveryConfig{
+		Name:              s.name,
+		UniqueID:          b.grill + "_" + s.id,
+		StateTopic:        b.topic(s, "state"),
+		UnitOfMeasurement: s.unit,
+		Device: discoveryDevice{
+			Identifiers:  []string{b.grill},
+			Name:         b.grill,
+			Manufacturer: "Traeger",
+			Model:        b.model.Name,
+		},
+	}
+
+	if s.temperature {
+		c.DeviceClass = "temperature"
+		c.UnitOfMeasurement = units.String()
+	}
+
+	return c
+}
+
+// publish sends the state of every sensor in status, preceded by the
+// discovery configs if they have not been sent or the units changed.
+func (b *bridge) publish(ctx context.Context, client mqtt.Client, status wifire.Status) error {
+	b.mutex.Lock()
+	announce := !b.announced || b.units != status.Units
+	b.mutex.Unlock()
+
+	if announce {
+		for _, s := range sensors {
+			payload, err := json.Marshal(b.config(s, status.Units))
+			if err != nil {
+				return err
+			}
+
+			if err := b.send(ctx, client, b.topic(s, "config"), true, payload); err != nil {
+				return err
+			}
+		}
+
+		b.mutex.Lock()
+		b.announced = true
+		b.units = status.Units
+		b.mutex.Unlock()
+	}
+
+	for _, s := range sensors {
+		if err := b.send(ctx, client, b.topic(s, "state"), false, []byte(s.value(status))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *bridge) send(ctx context.Context, client mqtt.Client, topic string, retained bool, payload []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	t := client.Publish(topic, 1, retained, payload)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.Done():
+		return t.Error()
+	}
+}
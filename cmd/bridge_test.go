@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/endobit/wifire"
+)
+
+// publishClient is an mqtt.Client that records the messages published to
+// it. Any other method panics.
+type publishClient struct {
+	mqtt.Client
+
+	mutex    sync.Mutex
+	messages map[string]string // payload by topic
+	retained map[string]bool
+	count    int
+}
+
+func (c *publishClient) Publish(topic string, _ byte, retained bool, payload interface{}) mqtt.Token {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.messages == nil {
+		c.messages = make(map[string]string)
+		c.retained = make(map[string]bool)
+	}
+
+	c.messages[topic] = string(payload.([]byte))
+	c.retained[topic] = retained
+	c.count++
+
+	return doneToken{}
+}
+
+// doneToken is an mqtt.Token that has completed successfully.
+type doneToken struct{}
+
+func (doneToken) Wait() bool                       { return true }
+func (doneToken) WaitTimeout(_ time.Duration) bool { return true }
+func (doneToken) Error() error                     { return nil }
+
+func (doneToken) Done() <-chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+
+	return ch
+}
+
+func TestBridgePublish(t *testing.T) {
+	var client publishClient
+
+	b := newBridge("homeassistant", "smoker", wifire.GrillModel{Name: "Ironwood 885"})
+	s := wifire.Status{Grill: 224, GrillSet: 225, PelletLevel: 80, Units: wifire.Fahrenheit}
+
+	if err := b.publish(context.Background(), &client, s); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(sensors) * 2; client.count != n {
+		t.Errorf("%d messages, want %d configs and states", client.count, n)
+	}
+
+	const config = "homeassistant/sensor/smoker/grill/config"
+
+	var c discoveryConfig
+	if err := json.Unmarshal([]byte(client.messages[config]), &c); err != nil {
+		t.Fatalf("%s: %s", config, err)
+	}
+
+	if !client.retained[config] {
+		t.Errorf("%s not retained", config)
+	}
+
+	if c.StateTopic != "homeassistant/sensor/smoker/grill/state" || c.UniqueID != "smoker_grill" ||
+		c.DeviceClass != "temperature" || c.UnitOfMeasurement != "°F" || c.Device.Model != "Ironwood 885" {
+		t.Errorf("config %+v", c)
+	}
+
+	if got := client.messages[c.StateTopic]; got != "224" || client.retained[c.StateTopic] {
+		t.Errorf("state %q retained %t, want 224 not retained", got, client.retained[c.StateTopic])
+	}
+
+	// Only the states are sent until the units change.
+	s.Grill = 226
+
+	if err := b.publish(context.Background(), &client, s); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(sensors) * 3; client.count != n || client.messages[c.StateTopic] != "226" {
+		t.Errorf("%d messages, grill %s, want %d and 226", client.count, client.messages[c.StateTopic], n)
+	}
+
+	s.Units = wifire.Celsius
+
+	if err := b.publish(context.Background(), &client, s); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal([]byte(client.messages[config]), &c); err != nil || c.UnitOfMeasurement != "°C" {
+		t.Errorf("config not announced in Celsius: %v %+v", err, c)
+	}
+}
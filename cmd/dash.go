@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/endobit/wifire"
+)
+
+func newDashCmd(l *login) *cobra.Command {
+	cmd := cobra.Command{
+		Use:   "dash",
+		Short: "Show a live dashboard of the grill status",
+		Long: `Dash shows the grill status full screen, updated as each status is
+received. If the output is not a terminal the status is logged instead.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			g, done, err := l.connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer done()
+
+			ch := make(chan wifire.Status, 1)
+
+			if err := g.SubscribeStatus(ch); err != nil {
+				return err
+			}
+
+			if !isTerminal(os.Stdout) {
+				go (&monitor{}).run(ch)
+				<-ctx.Done()
+
+				return nil
+			}
+
+			dash(ctx, os.Stdout, g.Name(), ch)
+
+			return nil
+		},
+	}
+
+	l.flags(cmd.Flags())
+
+	return &cmd
+}
+
+// The ANSI escape sequences used by the dashboard.
+const (
+	clearScreen = "\x1b[H\x1b[2J"
+	hideCursor  = "\x1b[?25l"
+	showCursor  = "\x1b[?25h"
+)
+
+// dash redraws the dashboard on w for every Status received on ch until ctx
+// is done.
+func dash(ctx context.Context, w io.Writer, grill string, ch <-chan wifire.Status) {
+	fmt.Fprint(w, hideCursor+clearScreen+"waiting for "+grill+"...\n")
+	defer fmt.Fprint(w, showCursor)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			fmt.Fprint(w, clearScreen+renderDash(grill, displayStatus(s)))
+		}
+	}
+}
+
+// renderDash returns the dashboard text for the Status s of the grill.
+func renderDash(grill string, s wifire.Status) string {
+	var b strings.Builder
+
+	u := s.Units.String()
+
+	fmt.Fprintf(&b, "%s  %s  %s\n\n", grill, s.SystemStatus, displayTime(s.Time))
+
+	if s.Error != nil {
+		fmt.Fprintf(&b, "error: %v\n\n", s.Error)
+	}
+
+	fmt.Fprintf(&b, "  %-8s %5d%s   set %d%s\n", "grill", s.Grill, u, s.GrillSet, u)
+
+	if s.ProbeConnected {
+		fmt.Fprintf(&b, "  %-8s %5d%s   set %d%s", "probe", s.Probe, u, s.ProbeSet, u)
+
+		if s.ProbeAlarmFired {
+			b.WriteString("   DONE")
+		}
+
+		b.WriteString("\n")
+	} else {
+		fmt.Fprintf(&b, "  %-8s %6s\n", "probe", "--")
+	}
+
+	fmt.Fprintf(&b, "  %-8s %5d%s\n", "ambient", s.Ambient, u)
+	fmt.Fprintf(&b, "  %-8s %5d%%\n", "pellets", s.PelletLevel)
+
+	if !s.TimerEnd.IsZero() && !s.TimerComplete {
+		fmt.Fprintf(&b, "  %-8s %6s\n", "timer", shortTimer(time.Until(s.TimerEnd)))
+	}
+
+	return b.String()
+}
+
+// shortTimer formats the time remaining d as h:mm:ss.
+func shortTimer(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+
+	d = d.Round(time.Second)
+
+	return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+// isTerminal returns true if f is a character device.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
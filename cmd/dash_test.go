@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/endobit/wifire"
+)
+
+func TestRenderDash(t *testing.T) {
+	inZone(t, time.UTC)
+
+	s := wifire.Status{
+		Time:         cookStart,
+		Ambient:      70,
+		Grill:        224,
+		GrillSet:     225,
+		PelletLevel:  80,
+		SystemStatus: wifire.StatusManualCook,
+		Units:        wifire.Fahrenheit,
+	}
+
+	want := "smoker  manual cook  12:00PM\n\n" +
+		"  grill      224°F   set 225°F\n" +
+		"  probe        --\n" +
+		"  ambient     70°F\n" +
+		"  pellets     80%\n"
+
+	if got := renderDash("smoker", s); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	s.ProbeConnected = true
+	s.Probe = 203
+	s.ProbeSet = 203
+	s.ProbeAlarmFired = true
+	s.TimerEnd = time.Now().Add(time.Hour + 30*time.Minute + 500*time.Millisecond)
+
+	want = "smoker  manual cook  12:00PM\n\n" +
+		"  grill      224°F   set 225°F\n" +
+		"  probe      203°F   set 203°F   DONE\n" +
+		"  ambient     70°F\n" +
+		"  pellets     80%\n" +
+		"  timer    1:30:00\n"
+
+	if got := renderDash("smoker", s); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+// demoData is a recorded three hour cook used by the demo command.
+//
+//go:embed demo.json
+var demoData []byte
+
+func newDemoCmd() *cobra.Command {
+	var speed float64
+
+	cmd := cobra.Command{
+		Use:   "demo",
+		Short: "Monitor a bundled sample cook without a grill",
+		Long: `Demo runs the monitor against a bundled sample cook log. No account or
+network connection is required.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if speed <= 0 {
+				return errors.New("speed must be positive")
+			}
+
+			data, err := readStatus(bytes.NewReader(demoData))
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			return replay(ctx, data, speed, &monitor{})
+		},
+	}
+
+	cmd.Flags().Float64Var(&speed, "speed", 600, "replay speed multiplier")
+
+	return &cmd
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDemo(t *testing.T) {
+	var log bytes.Buffer
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"demo", "--speed", "1000000"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(&log)
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Execute() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("demo did not finish")
+	}
+
+	if !strings.Contains(log.String(), "grill=") {
+		t.Errorf("no status logged:\n%s", log.String())
+	}
+}
+
+func TestDemoSpeed(t *testing.T) {
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"demo", "--speed", "0"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "speed must be positive") {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/endobit/wifire"
+)
+
+func newDoctorCmd(l *login) *cobra.Command {
+	var timeout time.Duration
+
+	cmd := cobra.Command{
+		Use:   "doctor",
+		Short: "Check the login and grill connection",
+		Long: `Doctor checks each stage of connecting to the grill in turn: the account
+flags, the Cognito login, the grills on the account, the MQTT connection, and
+a status update from the grill. A pass or fail line is printed for each stage
+and it stops at the first failure.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			opts, err := l.options()
+			if !check(cmd.OutOrStdout(), "config", err) {
+				return errDoctor
+			}
+
+			return doctor(ctx, cmd.OutOrStdout(), l, opts, timeout)
+		},
+	}
+
+	l.flags(cmd.Flags())
+	cmd.Flags().DurationVar(&timeout, "timeout", 2*time.Minute, "how long to wait for a status update")
+
+	return &cmd
+}
+
+var errDoctor = errors.New("check failed")
+
+// doctor runs the connection stages with the wifire options, printing a line
+// for each to out.
+func doctor(ctx context.Context, out io.Writer, l *login, opts []func(*wifire.WiFire), timeout time.Duration) error {
+	w, err := wifire.NewContext(ctx, opts...)
+	if !check(out, "login", err) {
+		return errDoctor
+	}
+
+	data, err := w.UserDataContext(ctx)
+	if err == nil && len(data.Things) == 0 {
+		err = errors.New("no grills found for this account")
+	}
+
+	if !check(out, "grills", err) {
+		return errDoctor
+	}
+
+	g := w.NewGrill(data.Things[0].Name)
+
+	err = g.ConnectContext(ctx)
+	if !check(out, "mqtt", err) {
+		return errDoctor
+	}
+
+	defer l.closer(w, g)()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ch := make(chan wifire.Status, 1)
+
+	err = g.SubscribeStatus(ch)
+	if err == nil {
+		select {
+		case s, ok := <-ch:
+			switch {
+			case !ok:
+				err = wifire.ErrNotConnected
+			case s.Error != nil:
+				err = s.Error
+			}
+		case <-ctx.Done():
+			err = fmt.Errorf("no status received, is the grill on? %w", ctx.Err())
+		}
+	}
+
+	if !check(out, "status", err) {
+		return errDoctor
+	}
+
+	return nil
+}
+
+// check prints the pass or fail line for the stage and returns true if it
+// passed.
+func check(out io.Writer, stage string, err error) bool {
+	if err == nil {
+		fmt.Fprintf(out, "PASS  %s\n", stage)
+		return true
+	}
+
+	fmt.Fprintf(out, "FAIL  %s: %s\n", stage, hint(err))
+
+	return false
+}
+
+// hint explains the typed wifire errors.
+func hint(err error) string {
+	switch {
+	case errors.Is(err, wifire.ErrInvalidCredentials):
+		return "check your username and password (" + err.Error() + ")"
+	case errors.Is(err, wifire.ErrCognitoUnavailable):
+		return "cannot reach the Traeger login service, try again later (" + err.Error() + ")"
+	case errors.Is(err, wifire.ErrMQTTConnect), errors.Is(err, wifire.ErrMQTTSubscribe):
+		return "cannot reach the Traeger MQTT broker (" + err.Error() + ")"
+	case errors.Is(err, wifire.ErrMQTTExpired):
+		return "the MQTT credentials expired (" + err.Error() + ")"
+	default:
+		return err.Error()
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/endobit/wifire"
+)
+
+// rejectedTransport is a MockTransport whose login is refused.
+type rejectedTransport struct {
+	wifire.MockTransport
+}
+
+func (t *rejectedTransport) Login(_ context.Context) error {
+	return fmt.Errorf("NotAuthorizedException: %w", wifire.ErrInvalidCredentials)
+}
+
+func TestDoctor(t *testing.T) {
+	good := wifire.Status{Time: cookStart, Grill: 225, GrillSet: 225, SystemStatus: wifire.StatusManualCook, Units: wifire.Fahrenheit}
+	bad := good
+	bad.Grill = 9999
+
+	tests := []struct {
+		name      string
+		transport wifire.Transport
+		want      string
+		failed    bool
+	}{
+		{
+			name:      "pass",
+			transport: &wifire.MockTransport{Data: []wifire.Status{good}, Interval: time.Millisecond},
+			want:      "PASS  login\nPASS  grills\nPASS  mqtt\nPASS  status\n",
+		},
+		{
+			name:      "status",
+			transport: &wifire.MockTransport{Data: []wifire.Status{bad}, Interval: time.Millisecond},
+			want:      "PASS  login\nPASS  grills\nPASS  mqtt\nFAIL  status: grill temperature 9999°F is out of range\n",
+			failed:    true,
+		},
+		{
+			name:      "login",
+			transport: &rejectedTransport{},
+			want:      "FAIL  login: check your username and password (NotAuthorizedException: invalid credentials)\n",
+			failed:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+
+			err := doctor(context.Background(), &out, &login{tokenCache: true},
+				[]func(*wifire.WiFire){wifire.UseTransport(tt.transport)}, time.Second)
+
+			if failed := errors.Is(err, errDoctor); failed != tt.failed || !failed && err != nil {
+				t.Errorf("got error %v", err)
+			}
+
+			if out.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
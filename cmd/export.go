@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/endobit/wifire"
+)
+
+func newExportCmd() *cobra.Command {
+	var input, output string
+
+	cmd := cobra.Command{
+		Use:   "export",
+		Short: "Convert a previous run to CSV",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fin, err := os.Open(input)
+			if err != nil {
+				return err
+			}
+			defer fin.Close()
+
+			fout, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+
+			skipped, err := exportCSV(fin, fout)
+			if err != nil {
+				fout.Close()
+				return err
+			}
+
+			if skipped > 0 {
+				slog.Warn("skipped malformed records", "count", skipped)
+			}
+
+			return fout.Close()
+		},
+	}
+
+	cmd.Flags().StringVarP(&input, "input", "i", "", "input file")
+	cmd.Flags().StringVarP(&output, "output", "o", "wifire.csv", "output file")
+
+	if err := cmd.MarkFlagRequired("input"); err != nil {
+		panic(err)
+	}
+
+	return &cmd
+}
+
+// exportCSV converts the newline delimited JSON Status records read from r to
+// CSV written to w, in the same columns as Plotter.WriteCSV. Lines that are not
+// valid Status records are skipped and counted.
+func exportCSV(r io.Reader, w io.Writer) (skipped int, err error) {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(wifire.CSVHeader()); err != nil {
+		return 0, err
+	}
+
+	var t0 time.Time
+
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		var status wifire.Status
+
+		if err := json.Unmarshal(s.Bytes(), &status); err != nil {
+			slog.Debug("skipping malformed record", "line", line, "error", err)
+			skipped++
+
+			continue
+		}
+
+		if t0.IsZero() {
+			t0 = status.Time
+		}
+
+		if err := cw.Write(wifire.CSVRecord(status, status.Time.Sub(t0))); err != nil {
+			return skipped, err
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return skipped, fmt.Errorf("reading input: %w", err)
+	}
+
+	cw.Flush()
+
+	return skipped, cw.Error()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExportCSV(t *testing.T) {
+	var in bytes.Buffer
+
+	enc := json.NewEncoder(&in)
+	for i, s := range cook(225, 200, 210, 220) {
+		if i == 1 {
+			in.WriteString("{not json\n\n") // a truncated record and a blank line
+		}
+
+		if err := enc.Encode(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+
+	skipped, err := exportCSV(&in, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if skipped != 2 {
+		t.Errorf("skipped %d, want 2", skipped)
+	}
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"time,elapsed,ambient,grill,grill_set,probe,probe_set",
+		"2024-07-04T12:00:00Z,0,70,200,225,100,200",
+		"2024-07-04T12:01:00Z,60,70,210,225,101,200",
+		"2024-07-04T12:02:00Z,120,70,220,225,102,200",
+	}
+
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(want))
+	}
+
+	for i := range want {
+		if got := strings.Join(rows[i], ","); got != want[i] {
+			t.Errorf("row %d: %q, want %q", i, got, want[i])
+		}
+	}
+}
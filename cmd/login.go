@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/spf13/pflag"
+
+	"github.com/endobit/wifire"
+)
+
+// login holds the account flags shared by the commands that talk to a grill.
+// Only the commands that log in register the flags.
+type login struct {
+	username   string
+	password   string
+	tokenCache bool
+}
+
+func (l *login) flags(fs *pflag.FlagSet) {
+	fs.StringVar(&l.username, "username", "", "account username")
+	fs.StringVar(&l.password, "password", "", "account password")
+	fs.BoolVar(&l.tokenCache, "token-cache", true, "save the login token between runs")
+}
+
+// connect logs in and connects to the account's first grill, extra are
+// added to the login options. The returned func must be called to disconnect
+// when done.
+func (l *login) connect(ctx context.Context, extra ...func(*wifire.WiFire)) (*wifire.Grill, func(), error) {
+	opts, err := l.options()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w, err := wifire.NewContext(ctx, append(opts, extra...)...)
+	if err != nil {
+		if errors.Is(err, wifire.ErrInvalidCredentials) {
+			return nil, nil, errors.New("login failed, check your username and password")
+		}
+
+		return nil, nil, err
+	}
+
+	data, err := w.UserDataContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(data.Things) == 0 {
+		return nil, nil, errors.New("no grills found for this account")
+	}
+
+	g := w.NewGrill(data.Things[0].Name)
+	if err := g.ConnectContext(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	return g, l.closer(w, g), nil
+}
+
+// options returns the wifire.New options for the account flags.
+func (l *login) options() ([]func(*wifire.WiFire), error) {
+	if l.username == "" || l.password == "" {
+		return nil, errors.New("--username and --password are required")
+	}
+
+	opts := []func(*wifire.WiFire){wifire.Credentials(l.username, l.password)}
+
+	if l.tokenCache {
+		path, err := wifire.DefaultTokenPath(l.username)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, wifire.TokenStorage(wifire.FileTokenStore{Path: path}))
+	}
+
+	return opts, nil
+}
+
+// closer returns the func that disconnects g when done. Without the token
+// cache it also signs out of w.
+func (l *login) closer(w *wifire.WiFire, g *wifire.Grill) func() {
+	if l.tokenCache {
+		return g.Disconnect // keep the tokens valid for the next run
+	}
+
+	return func() {
+		if err := w.Close(); err != nil {
+			slog.Warn("cannot sign out", "error", err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestLoginFlags(t *testing.T) {
+	root := newRootCmd()
+
+	for _, c := range root.Commands() {
+		want := false
+
+		switch c.Name() {
+		case "set-temp", "bridge", "dash", "doctor", "whoami":
+			want = true
+		}
+
+		if got := c.Flags().Lookup("username") != nil || c.InheritedFlags().Lookup("username") != nil; got != want {
+			t.Errorf("%s has --username %t, want %t", c.Name(), got, want)
+		}
+	}
+
+	if root.Flags().Lookup("username") == nil {
+		t.Error("the monitor has no --username")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/endobit/wifire"
+)
+
+// metrics serves the last Status received in the Prometheus text exposition
+// format.
+type metrics struct {
+	grill string
+
+	mutex sync.Mutex
+	last  *wifire.Status
+}
+
+func newMetrics(grill string) *metrics {
+	return &metrics{grill: grill}
+}
+
+// update records s as the latest Status. It is a no-op on a nil metrics.
+func (m *metrics) update(s wifire.Status) {
+	if m == nil || s.Error != nil {
+		return
+	}
+
+	m.mutex.Lock()
+	m.last = &s
+	m.mutex.Unlock()
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	m.mutex.Lock()
+	last := m.last
+	m.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	if last == nil {
+		return
+	}
+
+	m.gauge(w, "wifire_ambient_temp", "Ambient temperature.", last.Ambient)
+	m.gauge(w, "wifire_grill_temp", "Grill temperature.", last.Grill)
+	m.gauge(w, "wifire_grill_set", "Grill set point.", last.GrillSet)
+
+	if last.ProbeConnected {
+		m.gauge(w, "wifire_probe_temp", "Probe temperature.", last.Probe)
+		m.gauge(w, "wifire_probe_set", "Probe set point.", last.ProbeSet)
+	}
+}
+
+func (m *metrics) gauge(w io.Writer, name, help string, v int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s{grill=%s} %d\n",
+		name, help, name, name, strconv.Quote(m.grill), v)
+}
+
+// serveMetrics serves m at /metrics on addr. The returned func shuts down the
+// server.
+func serveMetrics(addr string, m *metrics) (func(), error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+
+	srv := http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("metrics server failed", "error", err)
+		}
+	}()
+
+	slog.Info("serving metrics", "addr", l.Addr().String())
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = srv.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/endobit/wifire"
+)
+
+// scrape returns the body served by m.
+func scrape(m *metrics) string {
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	return rec.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	m := newMetrics("smoker")
+
+	if body := scrape(m); body != "" {
+		t.Errorf("served %q before any status", body)
+	}
+
+	m.update(wifire.Status{Ambient: 70, Grill: 224, GrillSet: 225})
+
+	body := scrape(m)
+
+	for _, want := range []string{
+		"# TYPE wifire_grill_temp gauge\n",
+		`wifire_ambient_temp{grill="smoker"} 70` + "\n",
+		`wifire_grill_temp{grill="smoker"} 224` + "\n",
+		`wifire_grill_set{grill="smoker"} 225` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+
+	if strings.Contains(body, "wifire_probe") {
+		t.Errorf("probe served without a probe:\n%s", body)
+	}
+
+	m.update(wifire.Status{Grill: 230, GrillSet: 225, Probe: 150, ProbeSet: 203, ProbeConnected: true})
+	m.update(wifire.Status{Grill: 9999, Error: errors.New("out of range")}) // ignored
+
+	body = scrape(m)
+
+	for _, want := range []string{
+		`wifire_grill_temp{grill="smoker"} 230` + "\n",
+		`wifire_probe_temp{grill="smoker"} 150` + "\n",
+		`wifire_probe_set{grill="smoker"} 203` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/endobit/wifire"
+)
+
+// monitor logs every Status received. The optional fields add more outputs
+// for each Status.
+type monitor struct {
+	out     io.Writer // JSON log
+	metrics *metrics
+	notify  *notifier
+	webhook *webhook
+
+	// pelletLow and pelletOK are the pellet level percentages to warn below
+	// and clear above, the gap keeps a jittering level from repeating the
+	// warning. A zero pelletLow never warns.
+	pelletLow int
+	pelletOK  int
+
+	detector   wifire.EventDetector
+	offline    bool
+	pelletsLow bool // the low pellet warning was logged
+}
+
+// run handles every Status received on ch. It returns when ch is closed.
+func (m *monitor) run(ch <-chan wifire.Status) {
+	for s := range ch {
+		// The temperatures of an invalid Status aren't logged, metrics and
+		// events don't use it, and it is left out of the JSON log.
+		if s.Error != nil {
+			slog.Error("invalid status", "error", s.Error)
+			continue
+		}
+
+		if m.inactive(s) {
+			m.write(s)
+			continue
+		}
+
+		d := displayStatus(s)
+
+		attrs := []slog.Attr{
+			slog.Int("ambient", d.Ambient),
+			slog.Int("grill", d.Grill),
+			slog.Int("grill_set", d.GrillSet),
+		}
+
+		if s.ProbeConnected {
+			attrs = append(attrs,
+				slog.Int("probe", d.Probe),
+				slog.Int("probe_set", d.ProbeSet),
+				slog.Bool("probe_alarm", s.ProbeAlarmFired))
+		}
+
+		if s.CookElapsed >= time.Minute {
+			elapsed := strings.TrimSuffix(s.CookElapsed.Truncate(time.Minute).String(), "0s")
+			attrs = append(attrs, slog.String("cook", elapsed))
+		}
+
+		if s.InCustomCook {
+			attrs = append(attrs, slog.Int("cycle", s.CurrentCycle), slog.Int("step", s.CurrentStep))
+		}
+
+		if !s.TimerEnd.IsZero() && !s.TimerComplete {
+			attrs = append(attrs, slog.String("timer_end", displayTime(s.TimerEnd)))
+		}
+
+		slog.LogAttrs(context.TODO(), slog.LevelInfo, "", attrs...)
+		m.metrics.update(s)
+		m.notify.update(s)
+		m.webhook.update(s)
+
+		m.events(s)
+		m.pellets(s)
+		m.write(s)
+	}
+}
+
+// inactive returns true if the grill is offline, sleeping, or shutdown. The
+// transitions to and from inactive are logged once rather than logging
+// every Status, and inactive Status is not used for event detection.
+func (m *monitor) inactive(s wifire.Status) bool {
+	switch s.SystemStatus {
+	case wifire.StatusOffline, wifire.StatusSleeping, wifire.StatusShutdown:
+		if !m.offline {
+			m.offline = true
+			m.detector = wifire.EventDetector{}
+			slog.Info("grill offline", "status", s.SystemStatus.String())
+		}
+
+		return true
+	}
+
+	if m.offline {
+		m.offline = false
+		slog.Info("grill online", "status", s.SystemStatus.String())
+	}
+
+	return false
+}
+
+// write writes s to the JSON log if there is one.
+func (m *monitor) write(s wifire.Status) {
+	if m.out == nil {
+		return
+	}
+
+	s.Time = s.Time.In(displayLocation) // still RFC 3339, only the offset changes
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		slog.Error("cannot marshal", "error", err)
+	}
+
+	_, _ = m.out.Write(append(b, '\n')) // one write so a rotation never splits a line
+}
+
+// events logs the new events derived from s.
+func (m *monitor) events(s wifire.Status) {
+	for _, e := range m.detector.Update(s) {
+		slog.Info(e.String(), "event", e.Type.String(), "started", displayTime(e.Time))
+	}
+}
+
+// pellets logs a warning when the pellet level falls below pelletLow, and
+// again once it rises above pelletOK. Grills without a pellet sensor report
+// zero, which is ignored.
+func (m *monitor) pellets(s wifire.Status) {
+	if m.pelletLow == 0 || s.PelletLevel == 0 {
+		return
+	}
+
+	switch {
+	case !m.pelletsLow && s.PelletLevel < m.pelletLow:
+		m.pelletsLow = true
+		slog.Warn("low pellets", "pellet_level", s.PelletLevel)
+	case m.pelletsLow && s.PelletLevel > m.pelletOK:
+		m.pelletsLow = false
+		slog.Info("pellets ok", "pellet_level", s.PelletLevel)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/endobit/wifire"
+)
+
+// captureLog sends the default slog output to the returned buffer until the
+// test ends.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	return &buf
+}
+
+// runMonitor runs m on data and returns the log.
+func runMonitor(t *testing.T, m *monitor, data ...wifire.Status) string {
+	t.Helper()
+
+	buf := captureLog(t)
+
+	ch := make(chan wifire.Status, len(data))
+	for _, s := range data {
+		ch <- s
+	}
+	close(ch)
+
+	m.run(ch)
+
+	return buf.String()
+}
+
+func TestMonitorWriteZone(t *testing.T) {
+	inZone(t, time.FixedZone("MDT", -6*60*60))
+
+	var buf bytes.Buffer
+
+	in := wifire.Status{Time: time.Date(2024, 7, 4, 18, 30, 0, 0, time.UTC), Grill: 225, GrillSet: 225}
+	runMonitor(t, &monitor{out: &buf}, in)
+
+	if !strings.Contains(buf.String(), `"time":"2024-07-04T12:30:00-06:00"`) {
+		t.Errorf("time not in the display zone: %s", buf.String())
+	}
+
+	out, err := readStatus(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out) != 1 || !out[0].Time.Equal(in.Time) || out[0].Grill != in.Grill {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestMonitorWithoutProbe(t *testing.T) {
+	s := wifire.Status{Time: cookStart, Ambient: 70, Grill: 225, GrillSet: 225, SystemStatus: wifire.StatusManualCook}
+
+	log := runMonitor(t, &monitor{}, s)
+	if strings.Contains(log, "probe") || !strings.Contains(log, "grill=225") {
+		t.Errorf("got %s", log)
+	}
+
+	s.ProbeConnected = true
+	s.Probe = 150
+	s.ProbeSet = 200
+
+	log = runMonitor(t, &monitor{}, s)
+	if !strings.Contains(log, "probe=150") || !strings.Contains(log, "probe_set=200") {
+		t.Errorf("got %s", log)
+	}
+}
+
+func TestMonitorInactive(t *testing.T) {
+	var out bytes.Buffer
+
+	m := monitor{out: &out, metrics: newMetrics("smoker")}
+
+	active := wifire.Status{Time: cookStart, Grill: 225, GrillSet: 225, SystemStatus: wifire.StatusManualCook}
+	sleeping := wifire.Status{Time: cookStart.Add(time.Minute), Grill: 100, SystemStatus: wifire.StatusSleeping}
+	offline := sleeping
+	offline.SystemStatus = wifire.StatusOffline
+
+	log := runMonitor(t, &m, sleeping, offline, sleeping)
+
+	if n := strings.Count(log, "grill offline"); n != 1 {
+		t.Errorf("%d offline transitions, want 1:\n%s", n, log)
+	}
+
+	if strings.Contains(log, "grill=") {
+		t.Errorf("inactive status logged:\n%s", log)
+	}
+
+	if body := scrape(m.metrics); body != "" {
+		t.Errorf("metrics updated while inactive:\n%s", body)
+	}
+
+	if n := strings.Count(out.String(), "\n"); n != 3 {
+		t.Errorf("%d statuses written, want 3", n)
+	}
+
+	log = runMonitor(t, &m, active, active)
+
+	if n := strings.Count(log, "grill online"); n != 1 || !strings.Contains(log, "grill=225") {
+		t.Errorf("%d online transitions, want 1:\n%s", n, log)
+	}
+
+	if body := scrape(m.metrics); !strings.Contains(body, `wifire_grill_temp{grill="smoker"} 225`) {
+		t.Errorf("metrics not updated once active:\n%s", body)
+	}
+}
+
+func TestMonitorPelletsHysteresis(t *testing.T) {
+	buf := captureLog(t)
+
+	m := monitor{pelletLow: 15, pelletOK: 25}
+
+	for _, level := range []int{30, 16, 14, 16, 14, 15, 20, 24, 26, 24, 26, 0} {
+		m.pellets(wifire.Status{PelletLevel: level})
+	}
+
+	if n := strings.Count(buf.String(), "low pellets"); n != 1 {
+		t.Errorf("%d low pellet warnings, want 1:\n%s", n, buf)
+	}
+
+	if n := strings.Count(buf.String(), "pellets ok"); n != 1 {
+		t.Errorf("%d pellet clears, want 1:\n%s", n, buf)
+	}
+}
+
+func TestRootRejectsPelletLevelsBeforeLogin(t *testing.T) {
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--pellet-low", "30", "--pellet-ok", "20"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--pellet-low 30 is above --pellet-ok 20") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestMonitorInvalidStatus(t *testing.T) {
+	var out bytes.Buffer
+
+	m := monitor{out: &out, metrics: newMetrics("smoker")}
+
+	s := wifire.Status{Time: cookStart, Grill: 2000, GrillSet: 225, SystemStatus: wifire.StatusManualCook}
+	s.Error = errors.New("grill temperature 2000 out of range")
+
+	log := runMonitor(t, &m, s)
+
+	if !strings.Contains(log, "invalid status") {
+		t.Errorf("error not logged:\n%s", log)
+	}
+
+	if strings.Contains(log, "grill=") {
+		t.Errorf("temperatures of an invalid status logged:\n%s", log)
+	}
+
+	if body := scrape(m.metrics); body != "" {
+		t.Errorf("metrics updated from an invalid status:\n%s", body)
+	}
+
+	if out.Len() != 0 {
+		t.Errorf("invalid status written: %s", out.String())
+	}
+}
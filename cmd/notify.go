@@ -0,0 +1,65 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/endobit/wifire"
+)
+
+// notifier runs a command once when the probe reaches its set point. It is
+// re-armed when the probe set point changes or is cleared.
+type notifier struct {
+	command []string
+	grill   string
+
+	fired bool
+	set   int
+}
+
+// newNotifier returns a notifier for the command line cmd, split on white
+// space. The grill name and probe temperature are appended as arguments.
+func newNotifier(cmd, grill string) *notifier {
+	return &notifier{command: strings.Fields(cmd), grill: grill}
+}
+
+// update runs the command if s is the first Status with the probe at its set
+// point. It is a no-op on a nil notifier.
+func (n *notifier) update(s wifire.Status) {
+	if n == nil || s.Error != nil || !s.ProbeConnected {
+		return
+	}
+
+	if s.ProbeSet != n.set {
+		n.set = s.ProbeSet
+		n.fired = false
+	}
+
+	if n.fired || s.ProbeSet <= 0 || s.Probe < s.ProbeSet {
+		return
+	}
+
+	n.fired = true
+
+	probe := strconv.Itoa(s.Probe)
+
+	cmd := exec.Command(n.command[0], append(n.command[1:], n.grill, probe)...) //nolint:gosec // the user's own command
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"WIFIRE_GRILL="+n.grill,
+		"WIFIRE_PROBE="+probe,
+		"WIFIRE_PROBE_SET="+strconv.Itoa(s.ProbeSet),
+		"WIFIRE_UNITS="+s.Units.String())
+
+	slog.Info("probe reached set point", "probe", s.Probe, "probe_set", s.ProbeSet)
+
+	go func() {
+		if err := cmd.Run(); err != nil {
+			slog.Error("notify command failed", "command", n.command[0], "error", err)
+		}
+	}()
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/endobit/wifire"
+)
+
+func TestNotifierFiresOnce(t *testing.T) {
+	buf := captureLog(t)
+
+	n := newNotifier("true", "smoker")
+
+	probe := func(temp, set int) {
+		n.update(wifire.Status{Probe: temp, ProbeSet: set, ProbeConnected: true})
+	}
+
+	for _, temp := range []int{198, 200, 202, 199, 201} {
+		probe(temp, 200)
+	}
+
+	if got := strings.Count(buf.String(), "probe reached set point"); got != 1 {
+		t.Fatalf("fired %d times crossing the set point, want 1:\n%s", got, buf)
+	}
+
+	probe(201, 205) // re-armed by the new set point
+	probe(205, 205)
+	probe(206, 205)
+
+	if got := strings.Count(buf.String(), "probe reached set point"); got != 2 {
+		t.Errorf("fired %d times, want 2 after the set point changed:\n%s", got, buf)
+	}
+
+	if !strings.Contains(buf.String(), "probe=205 probe_set=205") {
+		t.Errorf("second firing not at the new set point:\n%s", buf)
+	}
+}
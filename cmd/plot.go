@@ -1,12 +1,17 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"gonum.org/v1/plot/vg"
 
 	"github.com/endobit/wifire"
 )
@@ -15,7 +20,16 @@ func newPlotCmd() *cobra.Command {
 	var (
 		input   string
 		output  string
-		markers []time.Duration
+		csvFile string
+		markers []string
+		lidOpen bool
+		stalls  bool
+		width   string
+		height  string
+		format  string
+		since   string
+		until   string
+		period  string
 	)
 
 	cmd := cobra.Command{
@@ -28,22 +42,55 @@ func newPlotCmd() *cobra.Command {
 			}
 			defer fin.Close()
 
-			var temps []wifire.Status
+			temps, err := readStatus(fin)
+			if err != nil {
+				return err
+			}
+
+			if len(temps) < 2 {
+				return wifire.ErrNotEnoughData
+			}
 
-			for s := bufio.NewScanner(fin); s.Scan(); {
-				var status wifire.Status
+			// Markers are elapsed from the start of the cook, not the window.
+			marks, err := parseMarkers(markers, temps[0].Time)
+			if err != nil {
+				return err
+			}
 
-				if err := json.Unmarshal(s.Bytes(), &status); err != nil {
+			if since != "" || until != "" {
+				if temps, err = filterTime(temps, since, until); err != nil {
 					return err
 				}
 
-				temps = append(temps, status)
+				marks = filterMarkers(marks, temps[0].Time, temps[len(temps)-1].Time)
+			}
+
+			if lidOpen {
+				for _, e := range wifire.DetectLidOpen(temps) {
+					marks = append(marks, wifire.Marker{Time: e.Time, Label: e.Type.String()})
+				}
+			}
+
+			for i := range temps {
+				temps[i] = displayStatus(temps[i])
+			}
+
+			if stdDev, overshoots := wifire.GrillStability(temps); stdDev > 0 || overshoots > 0 {
+				slog.Info("grill stability", "grill_set", temps[len(temps)-1].GrillSet,
+					"std_dev", math.Round(stdDev*10)/10, "overshoots", overshoots)
+			}
+
+			per, err := parsePeriod(period)
+			if err != nil {
+				return err
 			}
 
 			p := wifire.NewPlotter(wifire.PlotterOptions{
-				Title:   temps[0].Time.Format(time.ANSIC),
-				Data:    temps,
-				Markers: markers,
+				Period:          per,
+				Title:           temps[0].Time.In(displayLocation).Format(time.ANSIC),
+				Data:            temps,
+				Markers:         marks,
+				HighlightStalls: stalls,
 			})
 
 			plot, err := p.Plot()
@@ -51,13 +98,64 @@ func newPlotCmd() *cobra.Command {
 				return err
 			}
 
-			return plot.Save(800, 300, output)
+			if csvFile != "" {
+				if err := writeCSV(p, csvFile); err != nil {
+					return err
+				}
+			}
+
+			w, err := vg.ParseLength(width)
+			if err != nil {
+				return fmt.Errorf("invalid width %q", width)
+			}
+
+			h, err := vg.ParseLength(height)
+			if err != nil {
+				return fmt.Errorf("invalid height %q", height)
+			}
+
+			switch format {
+			case "":
+				format = strings.TrimPrefix(filepath.Ext(output), ".")
+			case "png", "svg", "pdf":
+				if !cmd.Flags().Changed("output") {
+					output = strings.TrimSuffix(output, filepath.Ext(output)) + "." + format
+				}
+			default:
+				return fmt.Errorf("unsupported format %q", format)
+			}
+
+			wt, err := plot.WriterTo(w, h, format)
+			if err != nil {
+				return err
+			}
+
+			fout, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+
+			if _, err := wt.WriteTo(fout); err != nil {
+				fout.Close()
+				return err
+			}
+
+			return fout.Close()
 		},
 	}
 
 	cmd.Flags().StringVarP(&input, "input", "i", "", "input file")
 	cmd.Flags().StringVarP(&output, "output", "o", "wifire.png", "output file")
-	cmd.Flags().DurationSliceVar(&markers, "marker", nil, "set a time marker (e.g. \"4h30m\") ")
+	cmd.Flags().StringVar(&csvFile, "csv", "", "also write the plotted data to a CSV file")
+	cmd.Flags().StringArrayVar(&markers, "marker", nil, "set a time marker with an optional label (e.g. \"4h30m=wrapped\"), repeat for more")
+	cmd.Flags().BoolVar(&lidOpen, "lid-open", false, "mark detected lid open events")
+	cmd.Flags().BoolVar(&stalls, "stalls", false, "shade detected probe stalls")
+	cmd.Flags().StringVar(&since, "since", "", "only plot data at or after this time (RFC 3339)")
+	cmd.Flags().StringVar(&until, "until", "", "only plot data at or before this time (RFC 3339)")
+	cmd.Flags().StringVar(&period, "period", "auto", "x-axis units auto, minute, hour, or day")
+	cmd.Flags().StringVar(&width, "width", "800", "plot width, points or with a unit of in, cm, mm, or pt")
+	cmd.Flags().StringVar(&height, "height", "300", "plot height, points or with a unit of in, cm, mm, or pt")
+	cmd.Flags().StringVar(&format, "format", "", "output format png, svg, or pdf (default from the output file name)")
 
 	if err := cmd.MarkFlagRequired("input"); err != nil {
 		panic(err)
@@ -65,3 +163,104 @@ func newPlotCmd() *cobra.Command {
 
 	return &cmd
 }
+
+// writeCSV writes the Plotter's data to the file name.
+func writeCSV(p *wifire.Plotter, name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err := p.WriteCSV(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func parsePeriod(s string) (wifire.Period, error) {
+	switch s {
+	case "auto":
+		return wifire.AutoPeriod, nil
+	case "minute":
+		return wifire.ByMinute, nil
+	case "hour":
+		return wifire.ByHour, nil
+	case "day":
+		return wifire.ByDay, nil
+	default:
+		return 0, fmt.Errorf("invalid period %q", s)
+	}
+}
+
+// filterTime returns the data between the RFC 3339 times since and until,
+// inclusive. An empty since or until is unbounded.
+func filterTime(data []wifire.Status, since, until string) ([]wifire.Status, error) {
+	var (
+		start, end time.Time
+		err        error
+	)
+
+	if since != "" {
+		if start, err = time.Parse(time.RFC3339, since); err != nil {
+			return nil, fmt.Errorf("invalid since time %q", since)
+		}
+	}
+
+	if until != "" {
+		if end, err = time.Parse(time.RFC3339, until); err != nil {
+			return nil, fmt.Errorf("invalid until time %q", until)
+		}
+	}
+
+	if !start.IsZero() && !end.IsZero() && !start.Before(end) {
+		return nil, errors.New("since must be before until")
+	}
+
+	var filtered []wifire.Status
+
+	for _, s := range data {
+		if (start.IsZero() || !s.Time.Before(start)) && (end.IsZero() || !s.Time.After(end)) {
+			filtered = append(filtered, s)
+		}
+	}
+
+	if len(filtered) < 2 {
+		return nil, fmt.Errorf("time window: %w", wifire.ErrNotEnoughData)
+	}
+
+	return filtered, nil
+}
+
+// filterMarkers returns the markers between start and end, inclusive.
+func filterMarkers(marks []wifire.Marker, start, end time.Time) []wifire.Marker {
+	var filtered []wifire.Marker
+
+	for _, m := range marks {
+		if !m.Time.Before(start) && !m.Time.After(end) {
+			filtered = append(filtered, m)
+		}
+	}
+
+	return filtered
+}
+
+// parseMarkers parses the marker flags, an elapsed time from t0 and an
+// optional label separated by "=".
+func parseMarkers(flags []string, t0 time.Time) ([]wifire.Marker, error) {
+	marks := make([]wifire.Marker, 0, len(flags))
+
+	for _, f := range flags {
+		elapsed, label, _ := strings.Cut(f, "=")
+
+		d, err := time.ParseDuration(elapsed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid marker %q: %w", f, err)
+		}
+
+		marks = append(marks, wifire.Marker{Time: t0.Add(d), Label: label})
+	}
+
+	return marks, nil
+}
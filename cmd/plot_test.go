@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/endobit/wifire"
+)
+
+var cookStart = time.Date(2024, 7, 4, 12, 0, 0, 0, time.UTC)
+
+// cook returns a Status a minute apart for each grill temperature, at the
+// set point, with the probe rising a degree a minute.
+func cook(set int, grill ...int) []wifire.Status {
+	data := make([]wifire.Status, len(grill))
+
+	for i, g := range grill {
+		data[i] = wifire.Status{
+			Time:           cookStart.Add(time.Duration(i) * time.Minute),
+			Ambient:        70,
+			Grill:          g,
+			GrillSet:       set,
+			Probe:          100 + i,
+			ProbeSet:       200,
+			ProbeConnected: true,
+			SystemStatus:   wifire.StatusManualCook,
+			Units:          wifire.Fahrenheit,
+		}
+	}
+
+	return data
+}
+
+// writeLog writes data as a JSON log in a temporary directory and returns
+// its path.
+func writeLog(t *testing.T, data []wifire.Status) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "wifire.json")
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, s := range data {
+		if err := enc.Encode(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return path
+}
+
+// runPlot runs the plot command with args and returns its log.
+func runPlot(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	var log strings.Builder
+
+	cmd := newRootCmd()
+	cmd.SetArgs(append([]string{"plot"}, args...))
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(&log)
+
+	err := cmd.Execute()
+
+	return log.String(), err
+}
+
+func TestPlotLogsStability(t *testing.T) {
+	in := writeLog(t, cook(225, 200, 225, 230, 220, 230, 220))
+	out := filepath.Join(t.TempDir(), "plot.png")
+
+	log, err := runPlot(t, "--input", in, "--output", out, "--log-format", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entry struct {
+		Msg        string  `json:"msg"`
+		GrillSet   int     `json:"grill_set"`
+		StdDev     float64 `json:"std_dev"`
+		Overshoots int     `json:"overshoots"`
+	}
+
+	if err := json.Unmarshal([]byte(log), &entry); err != nil {
+		t.Fatalf("%s: %s", err, log)
+	}
+
+	// From the first 225: errors of 0, 5, -5, 5, -5 and three crossings.
+	if entry.Msg != "grill stability" || entry.GrillSet != 225 || entry.StdDev != 4.5 || entry.Overshoots != 3 {
+		t.Errorf("got %+v", entry)
+	}
+
+	if _, err := os.Stat(out); err != nil {
+		t.Error(err)
+	}
+}
+
+// plotSVG runs the plot command on data with args and returns the SVG.
+func plotSVG(t *testing.T, data []wifire.Status, args ...string) string {
+	t.Helper()
+
+	out := filepath.Join(t.TempDir(), "plot.svg")
+
+	if _, err := runPlot(t, append([]string{"--input", writeLog(t, data), "--output", out}, args...)...); err != nil {
+		t.Fatalf("%v: %s", args, err)
+	}
+
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
+
+func TestPlotMarkerWithComma(t *testing.T) {
+	svg := plotSVG(t, cook(225, 200, 210, 220, 225), "--marker", "2m=salt, pepper", "--marker", "3m=wrapped")
+
+	for _, label := range []string{">salt, pepper<", ">wrapped<"} {
+		if !strings.Contains(svg, label) {
+			t.Errorf("marker %s missing", label)
+		}
+	}
+}
+
+func TestPlotFormat(t *testing.T) {
+	in := writeLog(t, cook(225, 200, 210, 220, 225))
+	dir := t.TempDir()
+
+	tests := []struct {
+		args   []string
+		output string
+		header string
+	}{
+		{[]string{"--format", "svg", "--output", filepath.Join(dir, "plot.out")}, "plot.out", "<?xml"},
+		{[]string{"--output", filepath.Join(dir, "plot.svg")}, "plot.svg", "<?xml"},
+		{[]string{"--format", "pdf", "--output", filepath.Join(dir, "plot.pdf")}, "plot.pdf", "%PDF-"},
+		{[]string{"--output", filepath.Join(dir, "plot.png")}, "plot.png", "\x89PNG"},
+	}
+
+	for _, tt := range tests {
+		if _, err := runPlot(t, append([]string{"--input", in}, tt.args...)...); err != nil {
+			t.Fatalf("%v: %s", tt.args, err)
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, tt.output))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(string(b), tt.header) {
+			t.Errorf("%v: starts with %q, want %q", tt.args, b[:min(len(b), 8)], tt.header)
+		}
+	}
+
+	if _, err := runPlot(t, "--input", in, "--format", "gif", "--output", filepath.Join(dir, "plot.gif")); err == nil {
+		t.Error("gif format accepted")
+	}
+}
+
+func TestPlotLidOpen(t *testing.T) {
+	data := cook(225, 225, 226, 200, 210, 222, 225)
+
+	if svg := plotSVG(t, data); strings.Contains(svg, ">lid open<") {
+		t.Error("lid open marked without --lid-open")
+	}
+
+	if svg := plotSVG(t, data, "--lid-open"); !strings.Contains(svg, ">lid open<") {
+		t.Error("lid open not marked with --lid-open")
+	}
+}
+
+func TestFilterTime(t *testing.T) {
+	data := cook(225, 200, 205, 210, 215, 220, 225)
+
+	got, err := filterTime(data, "2024-07-04T12:01:00Z", "2024-07-04T12:03:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 3 || got[0].Grill != 205 || got[2].Grill != 215 {
+		t.Errorf("got %+v, want 12:01 through 12:03", got)
+	}
+
+	if got, err = filterTime(data, "2024-07-04T08:04:00-04:00", ""); err != nil || len(got) != 2 || got[0].Grill != 220 {
+		t.Errorf("since only: %v %+v", err, got)
+	}
+
+	if _, err := filterTime(data, "2024-07-04T12:03:00Z", "2024-07-04T12:03:00Z"); err == nil {
+		t.Error("since equal to until accepted")
+	}
+
+	if _, err := filterTime(data, "2024-07-04T12:04:00Z", "2024-07-04T12:01:00Z"); err == nil {
+		t.Error("since after until accepted")
+	}
+
+	if _, err := filterTime(data, "2024-07-04T12:05:00Z", ""); !errors.Is(err, wifire.ErrNotEnoughData) {
+		t.Errorf("one status in the window: got %v, want ErrNotEnoughData", err)
+	}
+
+	if _, err := filterTime(data, "noon", ""); err == nil {
+		t.Error("invalid since accepted")
+	}
+}
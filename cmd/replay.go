@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/endobit/wifire"
+)
+
+func newReplayCmd() *cobra.Command {
+	var (
+		input string
+		speed float64
+	)
+
+	cmd := cobra.Command{
+		Use:   "replay",
+		Short: "Monitor a previous run as if it was live",
+		Long: `Replay runs the monitor against a log written with --output, with the
+recorded time between updates divided by the speed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if speed <= 0 {
+				return errors.New("speed must be positive")
+			}
+
+			fin, err := os.Open(input)
+			if err != nil {
+				return err
+			}
+			defer fin.Close()
+
+			data, err := readStatus(fin)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			return replay(ctx, data, speed, &monitor{})
+		},
+	}
+
+	cmd.Flags().StringVarP(&input, "input", "i", "", "input file")
+	cmd.Flags().Float64Var(&speed, "speed", 10, "replay speed multiplier")
+
+	if err := cmd.MarkFlagRequired("input"); err != nil {
+		panic(err)
+	}
+
+	return &cmd
+}
+
+// readStatus reads the newline delimited JSON Status records written by the
+// monitor.
+func readStatus(r io.Reader) ([]wifire.Status, error) {
+	var data []wifire.Status
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		var status wifire.Status
+
+		if err := json.Unmarshal(s.Bytes(), &status); err != nil {
+			return nil, err
+		}
+
+		data = append(data, status)
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// replay runs the monitor m on the recorded data as if it was being received
+// live from a grill. The time between each Status is the recorded time
+// divided by speed. It returns when all the data has been sent or ctx is
+// canceled.
+func replay(ctx context.Context, data []wifire.Status, speed float64, m *monitor) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	w, err := wifire.NewContext(ctx, wifire.UseTransport(&wifire.MockTransport{Data: data, Speed: speed}))
+	if err != nil {
+		return err
+	}
+
+	defer w.Close()
+
+	user, err := w.UserDataContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	g := w.NewGrill(user.Things[0].Name)
+	if err := g.ConnectContext(ctx); err != nil {
+		return err
+	}
+
+	sub := make(chan wifire.Status, 16) // updates are dropped if the monitor falls behind
+
+	if err := g.SubscribeStatus(sub); err != nil {
+		return err
+	}
+
+	// The subscription never ends, stop the monitor after the last Status.
+	last := data[len(data)-1].Time
+	ch := make(chan wifire.Status)
+
+	go func() {
+		defer close(ch)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case s, ok := <-sub:
+				if !ok {
+					return
+				}
+
+				ch <- s
+
+				if !s.Time.Before(last) {
+					return
+				}
+			}
+		}
+	}()
+
+	m.run(ch)
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplay(t *testing.T) {
+	buf := captureLog(t)
+
+	data := cook(225, 200, 210, 220)
+	for i := range data {
+		data[i].Time = cookStart.Add(time.Duration(i) * time.Second)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := replay(ctx, data, 100, &monitor{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %s at speed 100", elapsed)
+	}
+
+	for _, grill := range []string{"grill=200", "grill=210", "grill=220"} {
+		if n := strings.Count(buf.String(), grill+" "); n != 1 {
+			t.Errorf("%s logged %d times:\n%s", grill, n, buf)
+		}
+	}
+}
+
+func TestReplayLogJSON(t *testing.T) {
+	captureLog(t) // restores the default logger
+	inZone(t, time.UTC)
+
+	data := cook(225, 200, 210, 220)
+	for i := range data {
+		data[i].Time = cookStart.Add(time.Duration(i) * time.Second)
+	}
+
+	var log bytes.Buffer
+
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"replay", "--input", writeLog(t, data), "--speed", "100", "--log-format", "json", "--tz", "UTC"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(&log)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	var grills []int
+
+	for s := bufio.NewScanner(&log); s.Scan(); {
+		var entry struct {
+			Time  time.Time `json:"time"`
+			Level string    `json:"level"`
+			Grill *int      `json:"grill"`
+		}
+
+		if err := json.Unmarshal(s.Bytes(), &entry); err != nil {
+			t.Fatalf("%s: %q", err, s.Text())
+		}
+
+		if entry.Time.IsZero() || entry.Level == "" {
+			t.Errorf("missing time or level: %s", s.Text())
+		}
+
+		if entry.Grill != nil {
+			grills = append(grills, *entry.Grill)
+		}
+	}
+
+	if len(grills) != 3 || grills[0] != 200 || grills[2] != 220 {
+		t.Errorf("logged grill temperatures %v, want [200 210 220]", grills)
+	}
+}
@@ -2,14 +2,13 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
-	"os"
+	"net/url"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -42,10 +41,20 @@ func logger(level wifire.LogLevel, component, msg string) {
 
 func newRootCmd() *cobra.Command {
 	var (
-		output             string
-		username, password string
-		logLevel           string
-		debug              bool
+		l              login
+		output         string
+		outputMaxSize  int64
+		outputMaxFiles int
+		metricsAddr    string
+		notifyCmd      string
+		webhookURL     string
+		logLevel       string
+		logFormat      string
+		timeZone       string
+		units          string
+		pelletLow      int
+		pelletOK       int
+		debug          bool
 	)
 
 	cmd := cobra.Command{
@@ -59,49 +68,105 @@ func newRootCmd() *cobra.Command {
 				return fmt.Errorf("invalid log level %q", logLevel)
 			}
 
-			opts := clog.HandlerOptions{Level: level}
-			slog.SetDefault(slog.New(opts.NewHandler(os.Stderr)))
+			loc, err := time.LoadLocation(timeZone)
+			if err != nil {
+				return fmt.Errorf("invalid time zone %q", timeZone)
+			}
+
+			displayLocation = loc
+
+			if displayUnits, err = parseUnits(units); err != nil {
+				return err
+			}
+
+			var h slog.Handler
+
+			switch logFormat {
+			case "text":
+				format := clog.FormatOptions{
+					Time: displayTimeFormat,
+					Level: map[slog.Level]string{
+						slog.LevelDebug: "DBG",
+						slog.LevelInfo:  "INF",
+						slog.LevelWarn:  "WRN",
+						slog.LevelError: "ERR",
+					},
+				}
+
+				opts := clog.HandlerOptions{Level: level}
+				h = opts.NewHandler(cmd.ErrOrStderr(), clog.WithFormat(format))
+			case "json":
+				h = slog.NewJSONHandler(cmd.ErrOrStderr(), &slog.HandlerOptions{Level: level})
+			default:
+				return fmt.Errorf("invalid log format %q", logFormat)
+			}
+
+			slog.SetDefault(slog.New(zoneHandler{Handler: h, loc: loc}))
+
+			if debug {
+				wifire.Logger = logger
+			}
 
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if debug {
-				wifire.Logger = logger
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			if pelletLow > pelletOK {
+				return fmt.Errorf("--pellet-low %d is above --pellet-ok %d", pelletLow, pelletOK)
 			}
 
-			w, err := wifire.New(wifire.Credentials(username, password))
-			if err != nil {
-				panic(err)
+			if output != "" && outputMaxFiles < 1 {
+				return fmt.Errorf("invalid --output-max-files %d", outputMaxFiles)
+			}
+
+			if webhookURL != "" {
+				if _, err := url.ParseRequestURI(webhookURL); err != nil {
+					return fmt.Errorf("invalid webhook URL %q", webhookURL)
+				}
 			}
 
-			data, err := w.UserData()
+			g, done, err := l.connect(ctx)
 			if err != nil {
-				panic(err)
+				return err
+			}
+			defer done()
+
+			m := monitor{pelletLow: pelletLow, pelletOK: pelletOK}
+
+			if metricsAddr != "" {
+				m.metrics = newMetrics(g.Name())
+
+				shutdown, err := serveMetrics(metricsAddr, m.metrics)
+				if err != nil {
+					return err
+				}
+				defer shutdown()
 			}
 
-			g := w.NewGrill(data.Things[0].Name)
-			if err := g.Connect(); err != nil {
-				panic(err)
+			if strings.TrimSpace(notifyCmd) != "" {
+				m.notify = newNotifier(notifyCmd, g.Name())
 			}
 
-			defer g.Disconnect()
+			if webhookURL != "" {
+				m.webhook = newWebhook(ctx, webhookURL)
+			}
 
 			if output != "" {
-				fout, err := os.OpenFile(output, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o666)
+				fout, err := newRotator(output, outputMaxSize*1024*1024, outputMaxFiles)
 				if err != nil {
 					return err
 				}
 
 				defer fout.Close()
 
-				go status(g, fout)
-			} else {
-				go status(g, nil)
+				m.out = fout
 			}
 
-			catch := make(chan os.Signal, 1)
-			signal.Notify(catch, syscall.SIGINT, syscall.SIGTERM)
-			<-catch
+			go status(g, &m)
+
+			<-ctx.Done()
 
 			return nil
 		},
@@ -109,25 +174,36 @@ func newRootCmd() *cobra.Command {
 
 	info := strings.ToLower(slog.LevelInfo.String())
 	cmd.PersistentFlags().StringVar(&logLevel, "log", info, "log level")
+	cmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log format (text or json)")
 	cmd.PersistentFlags().BoolVar(&debug, "debug", false, "debug wifire API")
-	cmd.Flags().StringVar(&username, "username", "", "account username")
-	cmd.Flags().StringVar(&password, "password", "", "account password")
+	cmd.PersistentFlags().StringVar(&timeZone, "tz", "Local", "display time zone (e.g. \"UTC\", \"America/Denver\")")
+	cmd.PersistentFlags().StringVar(&units, "units", "auto", "display temperatures in \"f\", \"c\", or \"auto\" for the grill's units")
+	cmd.PersistentFlags().StringVar(&displayTimeFormat, "time-format", time.Kitchen, "display time format (Go reference time layout)")
+	l.flags(cmd.Flags())
 	cmd.Flags().StringVar(&output, "output", "", "log to file")
-
-	if err := cmd.MarkFlagRequired("username"); err != nil {
-		panic(err)
-	}
-	if err := cmd.MarkFlagRequired("password"); err != nil {
-		panic(err)
-	}
+	cmd.Flags().Int64Var(&outputMaxSize, "output-max-size", 0, "rotate the output file at this many megabytes (0 never rotates)")
+	cmd.Flags().IntVar(&outputMaxFiles, "output-max-files", 5, "number of rotated output files to keep")
+	cmd.Flags().StringVar(&notifyCmd, "notify-cmd", "", "command to run when the probe reaches its set point")
+	cmd.Flags().StringVar(&webhookURL, "webhook", "", "POST each status as JSON to this URL")
+	cmd.Flags().IntVar(&pelletLow, "pellet-low", 15, "warn when the pellet level falls below this percentage (0 never warns)")
+	cmd.Flags().IntVar(&pelletOK, "pellet-ok", 25, "clear the pellet warning when the level rises above this percentage")
+	cmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "serve Prometheus metrics on this address (e.g. \":9090\")")
 
 	cmd.AddCommand(newVersionCmd())
 	cmd.AddCommand(newPlotCmd())
+	cmd.AddCommand(newDemoCmd())
+	cmd.AddCommand(newExportCmd())
+	cmd.AddCommand(newReplayCmd())
+	cmd.AddCommand(newSetTempCmd(&l))
+	cmd.AddCommand(newBridgeCmd(&l))
+	cmd.AddCommand(newDashCmd(&l))
+	cmd.AddCommand(newDoctorCmd(&l))
+	cmd.AddCommand(newWhoamiCmd(&l))
 
 	return &cmd
 }
 
-func status(g *wifire.Grill, w io.Writer) {
+func status(g *wifire.Grill, m *monitor) {
 	ch := make(chan wifire.Status, 1)
 
 	if err := g.SubscribeStatus(ch); err != nil {
@@ -135,29 +211,5 @@ func status(g *wifire.Grill, w io.Writer) {
 		return
 	}
 
-	for {
-		s := <-ch
-		if s.Error != nil {
-			slog.Error("invalid status", "error", s.Error)
-		}
-
-		slog.LogAttrs(context.TODO(), slog.LevelInfo, "",
-			slog.Int("ambient", s.Ambient),
-			slog.Int("grill", s.Grill),
-			slog.Int("grill_set", s.GrillSet),
-			slog.Int("probe", s.Probe),
-			slog.Int("probe_set", s.ProbeSet),
-			slog.Bool("probe_alarm", s.ProbeAlarmFired))
-
-		if w != nil {
-			b, err := json.Marshal(s)
-			if err != nil {
-				slog.Error("cannot marshal", "error", err)
-			}
-
-			_, _ = w.Write(b)
-			_, _ = w.Write([]byte("\n"))
-		}
-	}
-
+	m.run(ch)
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// rotator is an io.Writer appending to a file that is rotated once it
+// reaches maxSize bytes. The file is renamed with a ".1" suffix, shifting
+// the older files up, and at most maxFiles rotated files are kept. A zero
+// maxSize never rotates.
+type rotator struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	f        *os.File
+	size     int64
+}
+
+func newRotator(path string, maxSize int64, maxFiles int) (*rotator, error) {
+	r := rotator{path: path, maxSize: maxSize, maxFiles: maxFiles}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func (r *rotator) open() error {
+	f, err := os.OpenFile(r.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o666)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	r.f = f
+	r.size = info.Size()
+
+	return nil
+}
+
+func (r *rotator) Write(p []byte) (int, error) {
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+
+	return n, err
+}
+
+// rotate closes the file, shifts the rotated files up one dropping the
+// oldest, and opens a new empty file.
+func (r *rotator) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+
+	for i := r.maxFiles - 1; i > 0; i-- {
+		err := os.Rename(r.name(i), r.name(i+1))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	if err := os.Rename(r.path, r.name(1)); err != nil {
+		return err
+	}
+
+	return r.open()
+}
+
+func (r *rotator) name(i int) string {
+	return fmt.Sprintf("%s.%d", r.path, i)
+}
+
+func (r *rotator) Close() error {
+	return r.f.Close()
+}
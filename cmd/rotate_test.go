@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wifire.json")
+
+	r, err := newRotator(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	} {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != want {
+			t.Errorf("%s: got %q, want %q", filepath.Base(name), b, want)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Error("more than max files kept")
+	}
+}
+
+func TestRootRejectsMaxFilesBeforeLogin(t *testing.T) {
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--output", filepath.Join(t.TempDir(), "out.json"), "--output-max-files", "0"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--output-max-files") {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/endobit/wifire"
+)
+
+func newSetTempCmd(l *login) *cobra.Command {
+	var (
+		timeout time.Duration
+		dryRun  bool
+	)
+
+	cmd := cobra.Command{
+		Use:   "set-temp <degrees>",
+		Short: "Set the grill temperature",
+		Long: `Set-temp sets the grill set point and waits for a status update from the
+grill confirming the change.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			degrees, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid temperature %q", args[0])
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			var opts []func(*wifire.WiFire)
+
+			if dryRun {
+				opts = append(opts, wifire.DryRun(logCommand))
+			}
+
+			g, done, err := l.connect(ctx, opts...)
+			if err != nil {
+				return err
+			}
+			defer done()
+
+			if dryRun {
+				return previewTemperature(ctx, g, degrees, timeout)
+			}
+
+			return setTemperature(ctx, g, degrees, timeout)
+		},
+	}
+
+	l.flags(cmd.Flags())
+	cmd.Flags().DurationVar(&timeout, "timeout", time.Minute, "how long to wait for the grill status and confirmation")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "log the command instead of sending it")
+
+	return &cmd
+}
+
+// logCommand logs a command instead of publishing it for --dry-run.
+func logCommand(topic string, payload []byte) {
+	slog.Info("dry run", "topic", topic, "payload", string(payload))
+}
+
+// previewTemperature waits up to timeout for a Status so the grill's units
+// are known and then sets the grill set point, which the DryRun option only
+// logs. There is no confirmation to wait for.
+func previewTemperature(ctx context.Context, g *wifire.Grill, degrees int, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if _, err := g.Status(ctx); err != nil {
+		return fmt.Errorf("no status from the grill: %w", err)
+	}
+
+	return g.SetTemperature(ctx, degrees)
+}
+
+// setTemperature waits for a Status so the grill's units are known, sets the
+// grill set point, and waits for a Status with the new set point. It all
+// must happen within timeout.
+func setTemperature(ctx context.Context, g *wifire.Grill, degrees int, timeout time.Duration) error {
+	ch := make(chan wifire.Status, 1)
+
+	if err := g.SubscribeStatus(ch); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := g.UnsubscribeStatus(); err != nil {
+			slog.Warn("cannot unsubscribe from status", "error", err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	s, err := nextStatus(ctx, ch)
+	if err != nil {
+		return fmt.Errorf("no status from the grill: %w", err)
+	}
+
+	if err := g.SetTemperature(ctx, degrees); err != nil {
+		return err
+	}
+
+	for s.GrillSet != degrees {
+		if s, err = nextStatus(ctx, ch); err != nil {
+			return fmt.Errorf("grill did not confirm the set point of %d: %w", degrees, err)
+		}
+	}
+
+	slog.Info("grill set", "grill_set", s.GrillSet, "grill", s.Grill)
+
+	return nil
+}
+
+// nextStatus returns the next valid Status received on ch.
+func nextStatus(ctx context.Context, ch <-chan wifire.Status) (wifire.Status, error) {
+	for {
+		select {
+		case s, ok := <-ch:
+			if !ok {
+				return wifire.Status{}, wifire.ErrNotConnected
+			}
+
+			if s.Error == nil {
+				return s, nil
+			}
+		case <-ctx.Done():
+			return wifire.Status{}, ctx.Err()
+		}
+	}
+}
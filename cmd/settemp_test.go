@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/endobit/wifire"
+)
+
+// newMockGrill returns a connected Grill replaying data every millisecond,
+// extra are added to the options.
+func newMockGrill(t *testing.T, data []wifire.Status, extra ...func(*wifire.WiFire)) *wifire.Grill {
+	t.Helper()
+
+	mock := wifire.MockTransport{Data: data, Interval: time.Millisecond}
+
+	w, err := wifire.New(append([]func(*wifire.WiFire){wifire.UseTransport(&mock)}, extra...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := w.NewGrill("mock")
+	if err := g.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(g.Disconnect)
+
+	return g
+}
+
+func TestSetTemperature(t *testing.T) {
+	var sent []string
+
+	record := func(_ string, payload []byte) { sent = append(sent, string(payload)) }
+
+	data := []wifire.Status{
+		{Time: time.Now(), Grill: 180, GrillSet: 180, SystemStatus: wifire.StatusManualCook, Units: wifire.Fahrenheit},
+		{Time: time.Now(), Grill: 185, GrillSet: 250, SystemStatus: wifire.StatusManualCook, Units: wifire.Fahrenheit},
+	}
+
+	g := newMockGrill(t, data, wifire.DryRun(record))
+
+	if err := setTemperature(context.Background(), g, 250, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sent) != 1 || sent[0] != `{"command":"11,250"}` {
+		t.Errorf("sent %q", sent)
+	}
+}
+
+func TestSetTemperatureNotConfirmed(t *testing.T) {
+	data := []wifire.Status{
+		{Time: time.Now(), Grill: 180, GrillSet: 180, SystemStatus: wifire.StatusManualCook, Units: wifire.Fahrenheit},
+	}
+
+	g := newMockGrill(t, data, wifire.DryRun(func(string, []byte) {}))
+
+	err := setTemperature(context.Background(), g, 250, 50*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want a deadline exceeded", err)
+	}
+}
+
+func TestSetTemperatureCelsius(t *testing.T) {
+	var sent []string
+
+	record := func(_ string, payload []byte) { sent = append(sent, string(payload)) }
+
+	data := []wifire.Status{
+		{Time: time.Now(), Grill: 80, GrillSet: 80, SystemStatus: wifire.StatusManualCook, Units: wifire.Celsius},
+		{Time: time.Now(), Grill: 82, GrillSet: 107, SystemStatus: wifire.StatusManualCook, Units: wifire.Celsius},
+	}
+
+	// 107 is below the Fahrenheit range, it is only valid once the units are known.
+	if err := setTemperature(context.Background(), newMockGrill(t, data, wifire.DryRun(record)), 107, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := previewTemperature(context.Background(), newMockGrill(t, data, wifire.DryRun(record)), 107, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sent) != 2 || sent[0] != `{"command":"11,107"}` || sent[1] != sent[0] {
+		t.Errorf("sent %q", sent)
+	}
+
+	err := previewTemperature(context.Background(), newMockGrill(t, data, wifire.DryRun(record)), 300, time.Second)
+	if err == nil || !strings.Contains(err.Error(), "°C") {
+		t.Errorf("300°C: got %v", err)
+	}
+}
+
+func TestNextStatus(t *testing.T) {
+	ch := make(chan wifire.Status, 2)
+	ch <- wifire.Status{Error: errors.New("bad payload")}
+	ch <- wifire.Status{Grill: 225}
+
+	if s, err := nextStatus(context.Background(), ch); err != nil || s.Grill != 225 {
+		t.Errorf("got %+v, %v, want the valid status", s, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := nextStatus(ctx, ch); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want a deadline exceeded", err)
+	}
+
+	close(ch)
+
+	if _, err := nextStatus(context.Background(), ch); !errors.Is(err, wifire.ErrNotConnected) {
+		t.Errorf("got %v, want ErrNotConnected", err)
+	}
+}
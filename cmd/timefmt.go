@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/endobit/wifire"
+)
+
+// The display settings shared by all the subcommands. These are set from the
+// persistent root command flags.
+var (
+	displayLocation   = time.Local
+	displayTimeFormat = time.Kitchen
+	displayUnits      *wifire.Units // nil displays the grill's own Units
+)
+
+// parseUnits parses the --units flag value.
+func parseUnits(s string) (*wifire.Units, error) {
+	var u wifire.Units
+
+	switch strings.ToLower(s) {
+	case "auto":
+		return nil, nil
+	case "f":
+		u = wifire.Fahrenheit
+	case "c":
+		u = wifire.Celsius
+	default:
+		return nil, fmt.Errorf("invalid units %q", s)
+	}
+
+	return &u, nil
+}
+
+// displayStatus returns s converted to the display units.
+func displayStatus(s wifire.Status) wifire.Status {
+	if displayUnits == nil {
+		return s
+	}
+
+	return s.In(*displayUnits)
+}
+
+// displayTime formats t using the display time zone and format.
+func displayTime(t time.Time) string {
+	return t.In(displayLocation).Format(displayTimeFormat)
+}
+
+// zoneHandler is an slog.Handler that converts the record time to the
+// display time zone before passing it on.
+type zoneHandler struct {
+	slog.Handler
+	loc *time.Location
+}
+
+func (h zoneHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.Time = r.Time.In(h.loc)
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h zoneHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return zoneHandler{Handler: h.Handler.WithAttrs(attrs), loc: h.loc}
+}
+
+func (h zoneHandler) WithGroup(name string) slog.Handler {
+	return zoneHandler{Handler: h.Handler.WithGroup(name), loc: h.loc}
+}
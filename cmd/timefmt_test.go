@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/endobit/wifire"
+)
+
+// inZone sets the display location until the test ends.
+func inZone(t *testing.T, loc *time.Location) {
+	t.Helper()
+
+	prev := displayLocation
+	displayLocation = loc
+	t.Cleanup(func() { displayLocation = prev })
+}
+
+func TestDisplayTime(t *testing.T) {
+	inZone(t, time.FixedZone("MDT", -6*60*60))
+
+	prev := displayTimeFormat
+	t.Cleanup(func() { displayTimeFormat = prev })
+
+	at := time.Date(2024, 7, 4, 18, 30, 0, 0, time.UTC)
+
+	if got := displayTime(at); got != "12:30PM" {
+		t.Errorf("got %s, want 12:30PM", got)
+	}
+
+	displayTimeFormat = time.RFC3339
+
+	if got := displayTime(at); got != "2024-07-04T12:30:00-06:00" {
+		t.Errorf("got %s, want 2024-07-04T12:30:00-06:00", got)
+	}
+}
+
+func TestZoneHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	h := zoneHandler{Handler: slog.NewJSONHandler(&buf, nil), loc: time.FixedZone("MDT", -6*60*60)}
+
+	r := slog.NewRecord(time.Date(2024, 7, 4, 18, 30, 0, 0, time.UTC), slog.LevelInfo, "", 0)
+	if err := h.WithAttrs([]slog.Attr{slog.Int("grill", 225)}).Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), `"time":"2024-07-04T12:30:00-06:00"`) {
+		t.Errorf("time not in the display zone: %s", buf.String())
+	}
+}
+
+func TestDisplayUnits(t *testing.T) {
+	prev := displayUnits
+	t.Cleanup(func() { displayUnits = prev })
+
+	s := wifire.Status{Ambient: 20, Grill: 107, GrillSet: 110, Probe: 60, ProbeSet: 95, ProbeConnected: true, Units: wifire.Celsius}
+
+	var err error
+
+	if displayUnits, err = parseUnits("auto"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := displayStatus(s); got.Grill != 107 || got.Units != wifire.Celsius {
+		t.Errorf("auto: got %+v", got)
+	}
+
+	if displayUnits, err = parseUnits("F"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := displayStatus(s)
+
+	if got.Units != wifire.Fahrenheit || got.Ambient != 68 || got.Grill != 225 || got.GrillSet != 230 ||
+		got.Probe != 140 || got.ProbeSet != 203 {
+		t.Errorf("f: got %+v", got)
+	}
+
+	if dash := renderDash("smoker", got); !strings.Contains(dash, "225°F   set 230°F") {
+		t.Errorf("dashboard not in Fahrenheit:\n%s", dash)
+	}
+
+	if _, err := parseUnits("k"); err == nil {
+		t.Error("invalid units accepted")
+	}
+}
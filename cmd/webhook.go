@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/endobit/wifire"
+)
+
+// The webhook delivery settings.
+const (
+	webhookQueue    = 16              // Status updates waiting to be posted
+	webhookAttempts = 3               // tries for each Status
+	webhookTimeout  = 5 * time.Second // per try
+	webhookBackoff  = time.Second     // between tries
+)
+
+// webhook POSTs each Status as JSON to a URL. Posting happens in the
+// background so a slow endpoint never stalls the monitor, updates that cannot
+// be queued or delivered are dropped.
+type webhook struct {
+	url    string
+	client *http.Client
+	queue  chan wifire.Status
+}
+
+// newWebhook returns a webhook that posts until ctx is done.
+func newWebhook(ctx context.Context, url string) *webhook {
+	h := webhook{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+		queue:  make(chan wifire.Status, webhookQueue),
+	}
+
+	go h.run(ctx)
+
+	return &h
+}
+
+// update queues s to be posted. It is a no-op on a nil webhook.
+func (h *webhook) update(s wifire.Status) {
+	if h == nil || s.Error != nil {
+		return
+	}
+
+	select {
+	case h.queue <- s:
+	default:
+		slog.Warn("webhook queue full, dropping status")
+	}
+}
+
+func (h *webhook) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s := <-h.queue:
+			if err := h.post(ctx, s); err != nil && ctx.Err() == nil {
+				slog.Warn("webhook failed, dropping status", "error", err)
+			}
+		}
+	}
+}
+
+// post sends s to the webhook URL, retrying failures.
+func (h *webhook) post(ctx context.Context, s wifire.Status) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = h.send(ctx, b)
+		if err == nil || attempt == webhookAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(webhookBackoff):
+		}
+	}
+}
+
+func (h *webhook) send(ctx context.Context, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: %s", h.url, resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/endobit/wifire"
+)
+
+func TestWebhook(t *testing.T) {
+	received := make(chan []byte, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+
+		b, _ := io.ReadAll(r.Body)
+		received <- b
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := wifire.Status{Grill: 225, GrillSet: 225, Time: time.Date(2024, 7, 4, 12, 0, 0, 0, time.UTC)}
+
+	h := newWebhook(ctx, srv.URL)
+	h.update(s)
+
+	select {
+	case b := <-received:
+		want, _ := json.Marshal(s)
+		if string(b) != string(want) {
+			t.Errorf("got %s, want %s", b, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+func TestRootRejectsWebhookBeforeLogin(t *testing.T) {
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--webhook", "not a url"}) // no credentials, login would fail first
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	if err := cmd.Execute(); err == nil || err.Error() != `invalid webhook URL "not a url"` {
+		t.Errorf("got %v", err)
+	}
+}
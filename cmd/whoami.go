@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/endobit/wifire"
+)
+
+func newWhoamiCmd(l *login) *cobra.Command {
+	var redact bool
+
+	cmd := cobra.Command{
+		Use:   "whoami",
+		Short: "Show the account information as JSON",
+		Long: `Whoami logs in and prints everything the WiFire API returns about the
+account, including the teams, grills, and grill models, as indented JSON.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			opts, err := l.options()
+			if err != nil {
+				return err
+			}
+
+			w, err := wifire.NewContext(ctx, opts...)
+			if err != nil {
+				return err
+			}
+
+			if !l.tokenCache {
+				defer func() {
+					if err := w.Close(); err != nil {
+						slog.Warn("cannot sign out", "error", err)
+					}
+				}()
+			}
+
+			data, err := w.UserDataContext(ctx)
+			if err != nil {
+				return err
+			}
+
+			return writeUserData(cmd.OutOrStdout(), data, redact)
+		},
+	}
+
+	l.flags(cmd.Flags())
+	cmd.Flags().BoolVar(&redact, "redact", false, "mask the names, email, and account IDs")
+
+	return &cmd
+}
+
+const redacted = "REDACTED"
+
+// personalFields are the user data fields masked by --redact, at any depth.
+var personalFields = map[string]bool{
+	"cognito":        true,
+	"customerId":     true,
+	"email":          true,
+	"familyName":     true,
+	"fullName":       true,
+	"givenName":      true,
+	"urbanAirshipId": true,
+	"userId":         true,
+	"username":       true,
+}
+
+// writeUserData writes data as indented JSON, with the personalFields
+// masked if redact is set.
+func writeUserData(w io.Writer, data any, redact bool) error {
+	if redact {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+
+		var v any
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+
+		data = redactFields(v)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(data)
+}
+
+// redactFields replaces the non-empty string values of the personalFields in
+// the decoded JSON v.
+func redactFields(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if s, ok := e.(string); ok && s != "" && personalFields[k] {
+				v[k] = redacted
+			} else {
+				v[k] = redactFields(e)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = redactFields(v[i])
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const userDataFixture = `{
+  "userId": "1a2b3c4d-0000-1111-2222-333344445555",
+  "givenName": "Pat",
+  "familyName": "Smoker",
+  "fullName": "Pat Smoker",
+  "email": "pat@example.com",
+  "username": "patsmoker",
+  "cognito": "us-west-2:0f0f0f0f-1111-2222-3333-444455556666",
+  "customerId": "C123456",
+  "urbanAirshipId": "",
+  "teams": [{"teamID": "t1", "teamName": "Backyard"}],
+  "things": [{
+    "thingName": "801F12345678",
+    "friendlyName": "Ironwood",
+    "userId": "1a2b3c4d-0000-1111-2222-333344445555",
+    "grillModel": {"name": "Ironwood 885"}
+  }]
+}`
+
+func TestWriteUserDataRedact(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := writeUserData(&buf, json.RawMessage(userDataFixture), true); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+
+	for _, secret := range []string{"pat@example.com", "Pat", "Smoker", "patsmoker", "1a2b3c4d", "us-west-2:", "C123456"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("%q not redacted:\n%s", secret, out)
+		}
+	}
+
+	for _, kept := range []string{"801F12345678", "Ironwood 885", "Backyard", `"urbanAirshipId": ""`} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("%q missing:\n%s", kept, out)
+		}
+	}
+}
+
+func TestWriteUserData(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := writeUserData(&buf, json.RawMessage(userDataFixture), false); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "pat@example.com") {
+		t.Errorf("email missing without --redact:\n%s", buf.String())
+	}
+}
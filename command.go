@@ -0,0 +1,163 @@
+package wifire
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// command is the payload published to the grill command topic. The format
+// matches what the Traeger app sends, a comma separated command code followed
+// by its arguments.
+type command struct {
+	Command string `json:"command"`
+}
+
+// The command codes.
+const (
+	cmdSetTemperature = "11"
+	cmdShutdown       = "17"
+)
+
+// The default grill set point limits in Fahrenheit.
+const (
+	minGrillTemp = 165
+	maxGrillTemp = 500
+)
+
+// modelLimits are the set point limits in Fahrenheit of the known models,
+// matched by the start of the lower case GrillModel Name.
+var modelLimits = []struct {
+	prefix   string
+	min, max int
+}{
+	{"pro 22", 180, 450},
+	{"pro 34", 180, 450},
+	{"pro 575", 165, 500},
+	{"pro 780", 165, 500},
+	{"ironwood", 165, 500},
+	{"timberline", 165, 500},
+}
+
+// TemperatureLimits returns the minimum and maximum grill set points for the
+// grill's model, in the Units of the last Status received. Until a Status is
+// received the grill's units are not known and the limits are in Fahrenheit,
+// call Status first to be sure of the units. The model is only known if
+// UserData was called before NewGrill, for unknown models the default 165 to
+// 500°F is returned.
+func (g *Grill) TemperatureLimits() (minTemp, maxTemp int) {
+	minTemp, maxTemp, _ = g.limits()
+	return minTemp, maxTemp
+}
+
+// limits returns the TemperatureLimits and their units.
+func (g *Grill) limits() (minTemp, maxTemp int, units Units) {
+	g.mutex.RLock()
+	last := g.last
+	g.mutex.RUnlock()
+
+	minTemp, maxTemp = minGrillTemp, maxGrillTemp
+	name := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(g.model.Name)), "traeger ")
+
+	known := false
+	for _, m := range modelLimits {
+		if strings.HasPrefix(name, m.prefix) {
+			minTemp, maxTemp, known = m.min, m.max, true
+			break
+		}
+	}
+
+	if !known {
+		g.unknown.Do(func() {
+			logf(LogInfo, "unrecognized grill model %q, using the default temperature limits", g.model.Name)
+		})
+	}
+
+	if last.Time.IsZero() || last.Units == Fahrenheit {
+		return minTemp, maxTemp, Fahrenheit
+	}
+
+	// Round inwards so the converted limits are still in range.
+	return int(math.Ceil(ConvertTemp(minTemp, Fahrenheit, last.Units))),
+		int(math.Floor(ConvertTemp(maxTemp, Fahrenheit, last.Units))),
+		last.Units
+}
+
+// DryRun is an option setting function for New(). Commands are passed to f,
+// with the topic and payload they would be published with, instead of being
+// sent to the grill.
+func DryRun(f func(topic string, payload []byte)) func(*WiFire) {
+	return func(w *WiFire) {
+		w.config.dryRun = f
+	}
+}
+
+func setTemperatureCommand(degrees int) command {
+	return command{Command: fmt.Sprintf("%s,%d", cmdSetTemperature, degrees)}
+}
+
+func (g *Grill) commandTopic() string {
+	return "prod/thing/" + g.name + "/command"
+}
+
+// SetTemperature sets the grill set point, degrees is in the grill's units.
+// If no Status has been received yet it waits for one to learn the units. An
+// error is returned if degrees is outside of the range supported by the
+// grill, see TemperatureLimits.
+func (g *Grill) SetTemperature(ctx context.Context, degrees int) error {
+	g.mutex.RLock()
+	known := !g.last.Time.IsZero()
+	g.mutex.RUnlock()
+
+	if !known {
+		if _, err := g.Status(ctx); err != nil {
+			return fmt.Errorf("grill units unknown: %w", err)
+		}
+	}
+
+	if minTemp, maxTemp, units := g.limits(); degrees < minTemp || degrees > maxTemp {
+		return fmt.Errorf("temperature %d is outside of the range %d%s to %d%s", degrees, minTemp, units, maxTemp, units)
+	}
+
+	return g.publish(ctx, setTemperatureCommand(degrees))
+}
+
+// Shutdown powers down the grill. An error is returned if the last Status
+// received shows the grill is already shutdown or offline. The last Status is
+// kept from any use of the update topic, SubscribeStatus, AddSubscriber,
+// SubscribeUsage, SubscribeEvents, Status, or WaitForTarget. Without one the
+// command is sent unchecked.
+func (g *Grill) Shutdown(ctx context.Context) error {
+	g.mutex.RLock()
+	state := g.last.SystemStatus
+	g.mutex.RUnlock()
+
+	switch state {
+	case StatusShutdown, StatusOffline:
+		return fmt.Errorf("grill is %s", state)
+	}
+
+	return g.publish(ctx, command{Command: cmdShutdown})
+}
+
+// publish sends the command to the grill, or to the DryRun func.
+func (g *Grill) publish(ctx context.Context, c command) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	if g.wifire.config.dryRun != nil {
+		g.wifire.config.dryRun(g.commandTopic(), b)
+		return nil
+	}
+
+	client, err := g.mqttClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	return wait(ctx, client.Publish(g.commandTopic(), g.wifire.config.qos, false, b))
+}
@@ -0,0 +1,233 @@
+package wifire
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemperatureLimits(t *testing.T) {
+	tests := []struct {
+		model    string
+		units    Units
+		min, max int
+	}{
+		{"Pro 22", Fahrenheit, 180, 450},
+		{"Traeger Ironwood 885", Fahrenheit, 165, 500},
+		{"Smokey McSmokeface", Fahrenheit, 165, 500},
+		{"", Fahrenheit, 165, 500},
+		{"Pro 22", Celsius, 83, 232},
+		{"Timberline 1300", Celsius, 74, 260},
+	}
+
+	for _, tt := range tests {
+		g, ft := newFakeGrill(t)
+		g.model.Name = tt.model
+
+		ch := make(chan Status, 1)
+		if err := g.SubscribeStatus(ch); err != nil {
+			t.Fatal(err)
+		}
+
+		deliverStatus(t, g, ft, Status{Time: time.Now(), Units: tt.units, SystemStatus: StatusIdle})
+		<-ch
+
+		if minTemp, maxTemp := g.TemperatureLimits(); minTemp != tt.min || maxTemp != tt.max {
+			t.Errorf("%q in %s: got %d to %d, want %d to %d", tt.model, tt.units, minTemp, maxTemp, tt.min, tt.max)
+		}
+	}
+}
+
+func TestTemperatureLimitsUnknownUnits(t *testing.T) {
+	g, _ := newFakeGrill(t)
+
+	if minTemp, maxTemp := g.TemperatureLimits(); minTemp != 165 || maxTemp != 500 {
+		t.Errorf("got %d to %d before any Status, want 165 to 500°F", minTemp, maxTemp)
+	}
+}
+
+func TestTemperatureLimitsLogsUnknownModelOnce(t *testing.T) {
+	var logged []string
+
+	prev := Logger
+	Logger = func(_ LogLevel, _, message string) { logged = append(logged, message) }
+	t.Cleanup(func() { Logger = prev })
+
+	g, _ := newFakeGrill(t)
+	g.model.Name = "Smokey McSmokeface"
+
+	g.TemperatureLimits()
+	g.TemperatureLimits()
+
+	n := 0
+	for _, msg := range logged {
+		if strings.Contains(msg, "unrecognized grill model") {
+			n++
+		}
+	}
+
+	if n != 1 {
+		t.Errorf("unrecognized model logged %d times, want once", n)
+	}
+}
+
+func TestSetTemperatureWaitsForUnits(t *testing.T) {
+	g, ft := newFakeGrill(t)
+
+	done := make(chan error, 1)
+	go func() { done <- g.SetTemperature(context.Background(), 107) }()
+
+	// 107°F would be refused, it is only valid once the grill reports Celsius.
+	eventually(t, func() bool {
+		return ft.client().deliver(g.updateTopic(), mockPayload(Status{Time: time.Now(), Units: Celsius, SystemStatus: StatusIdle}))
+	})
+
+	if err := <-done; err != nil {
+		t.Fatalf("107°C refused: %s", err)
+	}
+
+	if calls := ft.client().recorded("publish"); len(calls) != 1 || string(calls[0].payload) != `{"command":"11,107"}` {
+		t.Errorf("published %+v", calls)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	g, _ = newFakeGrill(t)
+	if err := g.SetTemperature(ctx, 225); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v without a status, want the deadline exceeded", err)
+	}
+}
+
+func TestSetTemperatureCelsius(t *testing.T) {
+	g, ft := newFakeGrill(t)
+
+	ch := make(chan Status, 1)
+	if err := g.SubscribeStatus(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	deliverStatus(t, g, ft, Status{Time: time.Now(), Units: Celsius, SystemStatus: StatusIdle})
+	<-ch
+
+	if err := g.SetTemperature(context.Background(), 107); err != nil {
+		t.Errorf("107°C refused: %s", err)
+	}
+
+	if err := g.SetTemperature(context.Background(), 225); err != nil {
+		t.Errorf("225°C refused: %s", err)
+	}
+
+	if err := g.SetTemperature(context.Background(), 300); err == nil {
+		t.Error("300°C accepted")
+	}
+}
+
+func TestSetTemperaturePublish(t *testing.T) {
+	g, ft := newFakeGrill(t)
+
+	ch := make(chan Status, 1)
+	if err := g.SubscribeStatus(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	deliverStatus(t, g, ft, Status{Time: time.Now(), Units: Fahrenheit, SystemStatus: StatusIdle})
+	<-ch
+
+	if err := g.SetTemperature(context.Background(), 225); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.client().recorded("publish")
+	if len(calls) != 1 {
+		t.Fatalf("%d publishes, want 1", len(calls))
+	}
+
+	if calls[0].topic != "prod/thing/fake/command" || string(calls[0].payload) != `{"command":"11,225"}` {
+		t.Errorf("published %s to %s", calls[0].payload, calls[0].topic)
+	}
+
+	if err := g.SetTemperature(context.Background(), 600); err == nil {
+		t.Error("600°F accepted")
+	}
+
+	if n := len(ft.client().recorded("publish")); n != 1 {
+		t.Errorf("out of range temperature published")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	g, ft := newFakeGrill(t)
+
+	ch := make(chan Status, 1)
+	if err := g.SubscribeStatus(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	deliverStatus(t, g, ft, Status{Time: time.Now(), Units: Fahrenheit, SystemStatus: StatusManualCook})
+	<-ch
+
+	if err := g.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.client().recorded("publish")
+	if len(calls) != 1 || calls[0].topic != "prod/thing/fake/command" || string(calls[0].payload) != `{"command":"17"}` {
+		t.Errorf("published %+v", calls)
+	}
+
+	for _, state := range []SystemStatus{StatusShutdown, StatusOffline} {
+		deliverStatus(t, g, ft, Status{Time: time.Now(), Units: Fahrenheit, SystemStatus: state})
+		<-ch
+
+		if err := g.Shutdown(context.Background()); err == nil {
+			t.Errorf("shutdown accepted when %s", state)
+		}
+	}
+
+	if n := len(ft.client().recorded("publish")); n != 1 {
+		t.Errorf("%d publishes, want 1", n)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	var sent []fakeCall
+
+	g, ft := newFakeGrill(t, DryRun(func(topic string, payload []byte) {
+		sent = append(sent, fakeCall{op: "publish", topic: topic, payload: payload})
+	}))
+
+	ch := make(chan Status, 1)
+	if err := g.SubscribeStatus(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	deliverStatus(t, g, ft, Status{Time: time.Now(), Units: Fahrenheit, SystemStatus: StatusManualCook})
+	<-ch
+
+	if err := g.SetTemperature(context.Background(), 225); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls := ft.client().recorded("publish"); len(calls) != 0 {
+		t.Errorf("published in dry run: %+v", calls)
+	}
+
+	want := []string{`{"command":"11,225"}`, `{"command":"17"}`}
+
+	if len(sent) != len(want) {
+		t.Fatalf("%d commands passed to DryRun, want %d", len(sent), len(want))
+	}
+
+	for i := range want {
+		if sent[i].topic != "prod/thing/fake/command" || string(sent[i].payload) != want[i] {
+			t.Errorf("dry run %s to %s, want %s", sent[i].payload, sent[i].topic, want[i])
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package wifire
+
+import (
+	"fmt"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// ConnState is the state of a Grill's MQTT connection.
+type ConnState int
+
+// The ConnStates of a Grill.
+const (
+	Disconnected ConnState = iota
+	Connecting
+	Connected
+	Reconnecting // the connection was lost and is being restored
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case Disconnected:
+		return "disconnected"
+	case Connecting:
+		return "connecting"
+	case Connected:
+		return "connected"
+	case Reconnecting:
+		return "reconnecting"
+	default:
+		return fmt.Sprintf("connstate(%d)", int(s))
+	}
+}
+
+// ConnectionState returns the current state of the MQTT connection.
+func (g *Grill) ConnectionState() ConnState {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	return g.state
+}
+
+// OnConnectionChange registers f to be called with the new state every time
+// the MQTT connection state changes. It is called from the MQTT client so it
+// must not block.
+func (g *Grill) OnConnectionChange(f func(ConnState)) {
+	g.mutex.Lock()
+	g.changes = append(g.changes, f)
+	g.mutex.Unlock()
+}
+
+// setState records the connection state and calls the OnConnectionChange
+// funcs if it changed. Changes reported by a client that has been replaced
+// are ignored, a nil c is always the current client.
+func (g *Grill) setState(c mqtt.Client, s ConnState) {
+	g.mutex.Lock()
+	if (c != nil && c != g.client) || g.state == s {
+		g.mutex.Unlock()
+		return
+	}
+
+	g.state = s
+	changes := append([]func(ConnState){}, g.changes...)
+	g.mutex.Unlock()
+
+	for _, f := range changes {
+		f(s)
+	}
+}
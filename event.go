@@ -0,0 +1,302 @@
+package wifire
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EventType identifies the kind of an Event.
+type EventType int
+
+// The EventTypes derived from the Status history.
+const (
+	_ EventType = iota
+	// LidOpen is a rapid grill temperature drop followed by a recovery while
+	// the set point is unchanged.
+	LidOpen
+	// Stall is a period where the probe temperature stops rising before
+	// reaching its set point.
+	Stall
+	// ProbeTargetReached is the probe reaching its set point.
+	ProbeTargetReached
+	// GrillAtSetPoint is the grill reaching its set point.
+	GrillAtSetPoint
+	// CookComplete is the grill starting its cool down.
+	CookComplete
+	// FaultDetected is the grill starting to report an error.
+	FaultDetected
+)
+
+func (t EventType) String() string {
+	switch t {
+	case LidOpen:
+		return "lid open"
+	case Stall:
+		return "stall"
+	case ProbeTargetReached:
+		return "probe target reached"
+	case GrillAtSetPoint:
+		return "grill at set point"
+	case CookComplete:
+		return "cook complete"
+	case FaultDetected:
+		return "fault detected"
+	default:
+		return fmt.Sprintf("event(%d)", int(t))
+	}
+}
+
+// Event is a notable occurrence derived from the Status history.
+type Event struct {
+	Type     EventType
+	Time     time.Time     // start of the event
+	Recovery time.Duration // time for the grill to recover, if applicable
+	Duration time.Duration // length of the event, if applicable
+}
+
+func (e Event) String() string {
+	switch e.Type {
+	case LidOpen:
+		return "lid opened — recovery ~" + shortDuration(e.Recovery)
+	case Stall:
+		return "stall — " + shortDuration(e.Duration)
+	default:
+		return e.Type.String()
+	}
+}
+
+const (
+	lidOpenDrop      = 20              // °F lost to count as a lid open
+	lidOpenWindow    = 2 * time.Minute // the drop must happen within this window
+	lidOpenTolerance = 5               // °F below the pre-drop temperature considered recovered
+)
+
+// DetectLidOpen returns a LidOpen Event for every rapid drop in grill
+// temperature that is followed by a recovery while the grill set point is
+// unchanged. The history must be in chronological order. Drops that have not
+// recovered by the end of the history are not reported. The temperatures are
+// compared in Fahrenheit whatever the Units of the history.
+func DetectLidOpen(history []Status) []Event {
+	var events []Event
+
+	for i := 0; i < len(history); i++ {
+		base := history[i]
+		if base.Error != nil {
+			continue
+		}
+
+		drop := -1
+
+		for j := i + 1; j < len(history) && history[j].Time.Sub(base.Time) <= lidOpenWindow; j++ {
+			if history[j].GrillSet != base.GrillSet {
+				break
+			}
+
+			if base.GrillFahrenheit()-history[j].GrillFahrenheit() >= lidOpenDrop {
+				drop = j
+				break
+			}
+		}
+
+		if drop < 0 {
+			continue
+		}
+
+		recovered := -1
+
+		for j := drop + 1; j < len(history); j++ {
+			if history[j].GrillSet != base.GrillSet {
+				break
+			}
+
+			if history[j].GrillFahrenheit() >= base.GrillFahrenheit()-lidOpenTolerance {
+				recovered = j
+				break
+			}
+		}
+
+		if recovered < 0 {
+			continue
+		}
+
+		events = append(events, Event{
+			Type:     LidOpen,
+			Time:     base.Time,
+			Recovery: history[recovered].Time.Sub(base.Time),
+		})
+
+		i = recovered
+	}
+
+	return events
+}
+
+const (
+	stallWindow  = 20 * time.Minute // probe velocity is measured over this window
+	stallRise    = 2                // degrees of probe rise within the window considered stalled
+	stallMinimum = 30 * time.Minute // shorter flat spots are not reported
+)
+
+// DetectStalls returns a Stall Event for every period of at least 30 minutes
+// where the probe temperature rises no more than 2 degrees in 20 minutes
+// while the probe is above ambient and below its set point. The history must
+// be in chronological order. A stall still in progress at the end of the
+// history is reported with the Duration so far.
+func DetectStalls(history []Status) []Event {
+	var (
+		events     []Event
+		start, end time.Time
+		k          int // index of the start of the velocity window
+	)
+
+	flush := func() {
+		if !start.IsZero() && end.Sub(start) >= stallMinimum {
+			events = append(events, Event{Type: Stall, Time: start, Duration: end.Sub(start)})
+		}
+
+		start, end = time.Time{}, time.Time{}
+	}
+
+	for i := range history {
+		s := history[i]
+
+		for k+1 < i && s.Time.Sub(history[k+1].Time) >= stallWindow {
+			k++
+		}
+
+		if s.Error != nil || !stalling(history[k], s) || s.Time.Sub(history[k].Time) < stallWindow {
+			flush()
+			continue
+		}
+
+		if start.IsZero() {
+			start = history[k].Time
+		}
+
+		end = s.Time
+	}
+
+	flush()
+
+	return events
+}
+
+// stalling returns true if the probe rose by no more than stallRise from
+// before to after and is still short of its set point.
+func stalling(before, after Status) bool {
+	if !before.ProbeConnected || !after.ProbeConnected || before.Error != nil {
+		return false
+	}
+
+	if after.Probe <= after.Ambient || (after.ProbeSet > 0 && after.Probe >= after.ProbeSet) {
+		return false
+	}
+
+	return after.Probe-before.Probe <= stallRise
+}
+
+// eventWindow is how much Status history the EventDetector keeps for
+// detecting lid opens.
+const eventWindow = 30 * time.Minute
+
+// EventDetector derives Events from consecutive Status updates, as
+// SubscribeEvents does, for callers that already have the Status. Each
+// event fires once per transition: ProbeTargetReached and GrillAtSetPoint
+// once per set point, so a temperature hovering around it does not fire
+// again. The zero value is ready to use.
+type EventDetector struct {
+	last         Status
+	history      []Status
+	lidEnd       time.Time // recovery of the last lid open reported
+	probeSet     int       // ProbeSet that probeReached applies to
+	probeReached bool
+	grillSet     int // GrillSet that grillReached applies to
+	grillReached bool
+}
+
+// Update adds s, which must not have an Error, and returns the new Events.
+func (d *EventDetector) Update(s Status) []Event {
+	var events []Event
+
+	prev := d.last
+	d.last = s
+
+	if !s.ProbeConnected || s.ProbeSet != d.probeSet {
+		d.probeSet = s.ProbeSet
+		d.probeReached = false
+	}
+
+	if s.ProbeConnected && s.ProbeSet > 0 && s.Probe >= s.ProbeSet && !d.probeReached {
+		d.probeReached = true
+		events = append(events, Event{Type: ProbeTargetReached, Time: s.Time})
+	}
+
+	if s.GrillSet != d.grillSet {
+		d.grillSet = s.GrillSet
+		d.grillReached = false
+	}
+
+	if s.GrillSet > 0 && s.Grill >= s.GrillSet && !d.grillReached {
+		d.grillReached = true
+		events = append(events, Event{Type: GrillAtSetPoint, Time: s.Time})
+	}
+
+	if !prev.Time.IsZero() {
+		if s.SystemStatus == StatusCoolDown && prev.SystemStatus != StatusCoolDown {
+			events = append(events, Event{Type: CookComplete, Time: s.Time})
+		}
+
+		if s.Errors != 0 && prev.Errors == 0 {
+			events = append(events, Event{Type: FaultDetected, Time: s.Time})
+		}
+	}
+
+	d.history = append(d.history, s)
+	for len(d.history) > 0 && s.Time.Sub(d.history[0].Time) > eventWindow {
+		d.history = d.history[1:]
+	}
+
+	for _, e := range DetectLidOpen(d.history) {
+		// As the window slides the same drop can be found again from a
+		// later starting point, only report events after the last
+		// recovery.
+		if e.Time.After(d.lidEnd) {
+			d.lidEnd = e.Time.Add(e.Recovery)
+			events = append(events, e)
+		}
+	}
+
+	return events
+}
+
+// shortDuration formats d rounded to the minute without the trailing zero
+// seconds (e.g. "4m" rather than "4m0s").
+func shortDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d == 0 {
+		return "<1m"
+	}
+
+	return strings.TrimSuffix(d.String(), "0s")
+}
+
+// SubscribeEvents subscribes to the prod/thing/update for the grill and
+// pushes the Events derived from the Status updates (ProbeTargetReached,
+// GrillAtSetPoint, LidOpen, CookComplete, and FaultDetected) to the channel.
+// As with SubscribeStatus the oldest Event is dropped when the channel is
+// full, and the channel is closed when unsubscribed.
+func (g *Grill) SubscribeEvents(ch chan Event) error {
+	g.mutex.Lock()
+	if g.events == nil || g.events.ch != ch {
+		g.derive = EventDetector{} // a new subscriber starts afresh
+	}
+	g.mutex.Unlock()
+
+	return subscribeChannel(g, &g.events, ch)
+}
+
+// UnsubscribeEvents stops the SubscribeEvents updates and closes the channel.
+func (g *Grill) UnsubscribeEvents() error {
+	return unsubscribeChannel(g, &g.events)
+}
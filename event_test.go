@@ -0,0 +1,206 @@
+package wifire
+
+import (
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2024, 7, 4, 12, 0, 0, 0, time.UTC)
+
+// grillSeries returns a Status a minute apart for each grill temperature,
+// at the set point, in Fahrenheit.
+func grillSeries(set int, grill ...int) []Status {
+	history := make([]Status, len(grill))
+
+	for i, g := range grill {
+		history[i] = Status{
+			Time:         t0.Add(time.Duration(i) * time.Minute),
+			Grill:        g,
+			GrillSet:     set,
+			SystemStatus: StatusManualCook,
+			Units:        Fahrenheit,
+		}
+	}
+
+	return history
+}
+
+// setPointChange changes the GrillSet to set from history[i] on.
+func setPointChange(history []Status, i, set int) []Status {
+	for ; i < len(history); i++ {
+		history[i].GrillSet = set
+	}
+
+	return history
+}
+
+func inCelsius(history []Status) []Status {
+	for i := range history {
+		history[i].Units = Celsius
+	}
+
+	return history
+}
+
+func TestDetectLidOpen(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []Status
+		want    []Event
+	}{
+		{
+			name:    "recovers",
+			history: grillSeries(225, 225, 226, 200, 210, 222, 225),
+			want:    []Event{{Type: LidOpen, Time: t0, Recovery: 4 * time.Minute}},
+		},
+		{
+			name:    "never recovers",
+			history: grillSeries(225, 225, 226, 200, 205, 210, 215),
+		},
+		{
+			name:    "slow drop",
+			history: grillSeries(225, 225, 220, 215, 210, 205, 225),
+		},
+		{
+			name:    "set point change",
+			history: setPointChange(grillSeries(225, 225, 226, 200, 190, 185, 225), 3, 180),
+		},
+		{
+			name:    "celsius", // a 12°C drop is over 20°F
+			history: inCelsius(grillSeries(107, 107, 108, 95, 100, 105, 107)),
+			want:    []Event{{Type: LidOpen, Time: t0, Recovery: 4 * time.Minute}},
+		},
+	}
+
+	for _, tt := range tests {
+		got := DetectLidOpen(tt.history)
+
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %+v, want %+v", tt.name, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func eventTypes(events []Event) []EventType {
+	types := make([]EventType, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+
+	return types
+}
+
+func TestEventDetectorOncePerTransition(t *testing.T) {
+	history := grillSeries(225, 200, 224, 225, 223, 226, 224, 225)
+
+	// The probe reaches its set point, hovers around it, then the set point
+	// changes and it is reached again.
+	probes := []struct{ probe, set int }{{150, 160}, {160, 160}, {159, 160}, {161, 160}, {161, 170}, {170, 170}, {171, 170}}
+	for i, p := range probes {
+		history[i].ProbeConnected = true
+		history[i].Probe = p.probe
+		history[i].ProbeSet = p.set
+	}
+
+	// A fault and a cool down, each reported once.
+	history[3].Errors = 1
+	history[4].Errors = 1
+	history[5].SystemStatus = StatusCoolDown
+	history[6].SystemStatus = StatusCoolDown
+
+	count := make(map[EventType]int)
+
+	var d EventDetector
+	for _, s := range history {
+		for _, e := range d.Update(s) {
+			count[e.Type]++
+		}
+	}
+
+	want := map[EventType]int{
+		GrillAtSetPoint:    1,
+		ProbeTargetReached: 2,
+		FaultDetected:      1,
+		CookComplete:       1,
+	}
+
+	for typ, n := range want {
+		if count[typ] != n {
+			t.Errorf("%s fired %d times, want %d", typ, count[typ], n)
+		}
+	}
+
+	if len(count) != len(want) {
+		t.Errorf("unexpected events %v", count)
+	}
+}
+
+func TestEventDetectorGrillSetPointChange(t *testing.T) {
+	var d EventDetector
+
+	history := append(grillSeries(225, 225, 226), grillSeries(250, 240, 250, 251)...)
+
+	var types []EventType
+	for _, s := range history {
+		types = append(types, eventTypes(d.Update(s))...)
+	}
+
+	if len(types) != 2 || types[0] != GrillAtSetPoint || types[1] != GrillAtSetPoint {
+		t.Errorf("got %v, want GrillAtSetPoint once per set point", types)
+	}
+}
+
+func TestEventDetectorLidOpenOnce(t *testing.T) {
+	// One lid open, then a long steady period as the window slides past it.
+	grill := []int{225, 225, 190, 200, 215, 224}
+	for i := 0; i < 40; i++ {
+		grill = append(grill, 225)
+	}
+
+	var d EventDetector
+
+	lids := 0
+	for _, s := range grillSeries(225, grill...) {
+		for _, e := range d.Update(s) {
+			if e.Type == LidOpen {
+				lids++
+			}
+		}
+	}
+
+	if lids != 1 {
+		t.Errorf("lid open fired %d times, want 1", lids)
+	}
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	g, ft := newFakeGrill(t)
+
+	ch := make(chan Event, 4)
+	if err := g.SubscribeEvents(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range grillSeries(225, 200, 225) {
+		deliverStatus(t, g, ft, s)
+	}
+
+	if e := <-ch; e.Type != GrillAtSetPoint || !e.Time.Equal(t0.Add(time.Minute)) {
+		t.Errorf("got %+v, want GrillAtSetPoint at the second status", e)
+	}
+
+	if err := g.UnsubscribeEvents(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed")
+	}
+}
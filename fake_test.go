@@ -0,0 +1,264 @@
+package wifire
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// fakeTransport is a Transport whose MQTT clients record every call and only
+// deliver the messages a test sends them.
+type fakeTransport struct {
+	expires      time.Time // of each client, zero never expires
+	connectErr   error     // returned by Connect
+	subscribeErr error     // returned by Subscribe
+
+	mutex   sync.Mutex
+	clients []*fakeClient
+}
+
+func (t *fakeTransport) Login(_ context.Context) error {
+	return nil
+}
+
+func (t *fakeTransport) UserData(_ context.Context) ([]byte, error) {
+	return json.Marshal(getUserDataResponse{
+		Things: []thing{{Name: "fake", FriendlyName: "fake"}},
+	})
+}
+
+func (t *fakeTransport) MQTTClient(_ context.Context, handlers func(*mqtt.ClientOptions)) (mqtt.Client, time.Time, error) {
+	opts := mqtt.NewClientOptions()
+	handlers(opts)
+
+	c := &fakeClient{transport: t, opts: opts, handlers: make(map[string]mqtt.MessageHandler)}
+
+	t.mutex.Lock()
+	t.clients = append(t.clients, c)
+	t.mutex.Unlock()
+
+	return c, t.expires, nil
+}
+
+// client returns the most recent client.
+func (t *fakeTransport) client() *fakeClient {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if len(t.clients) == 0 {
+		return nil
+	}
+
+	return t.clients[len(t.clients)-1]
+}
+
+// fakeCall is a Subscribe, Unsubscribe or Publish made on a fakeClient.
+type fakeCall struct {
+	op      string
+	topic   string
+	qos     byte
+	payload []byte
+}
+
+type fakeClient struct {
+	transport *fakeTransport
+	opts      *mqtt.ClientOptions
+
+	mutex     sync.Mutex
+	connected bool
+	calls     []fakeCall
+	handlers  map[string]mqtt.MessageHandler
+}
+
+func (c *fakeClient) IsConnected() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.connected
+}
+
+func (c *fakeClient) IsConnectionOpen() bool {
+	return c.IsConnected()
+}
+
+func (c *fakeClient) Connect() mqtt.Token {
+	if c.transport.connectErr != nil {
+		return fakeToken{c.transport.connectErr}
+	}
+
+	c.mutex.Lock()
+	c.connected = true
+	c.mutex.Unlock()
+
+	if c.opts.OnConnect != nil {
+		c.opts.OnConnect(c)
+	}
+
+	return fakeToken{}
+}
+
+func (c *fakeClient) Disconnect(_ uint) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.connected = false
+}
+
+func (c *fakeClient) Publish(topic string, qos byte, _ bool, payload interface{}) mqtt.Token {
+	b, _ := payload.([]byte)
+	c.record(fakeCall{op: "publish", topic: topic, qos: qos, payload: b})
+
+	return fakeToken{}
+}
+
+func (c *fakeClient) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+	c.record(fakeCall{op: "subscribe", topic: topic, qos: qos})
+
+	if c.transport.subscribeErr != nil {
+		return fakeToken{c.transport.subscribeErr}
+	}
+
+	c.mutex.Lock()
+	c.handlers[topic] = callback
+	c.mutex.Unlock()
+
+	return fakeToken{}
+}
+
+func (c *fakeClient) SubscribeMultiple(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token {
+	for topic, qos := range filters {
+		c.Subscribe(topic, qos, callback)
+	}
+
+	return fakeToken{}
+}
+
+func (c *fakeClient) Unsubscribe(topics ...string) mqtt.Token {
+	for _, topic := range topics {
+		c.record(fakeCall{op: "unsubscribe", topic: topic})
+		runtime.Gosched() // a round trip to the broker
+
+		c.mutex.Lock()
+		delete(c.handlers, topic)
+		c.mutex.Unlock()
+	}
+
+	return fakeToken{}
+}
+
+func (c *fakeClient) AddRoute(_ string, _ mqtt.MessageHandler) {}
+
+func (c *fakeClient) OptionsReader() mqtt.ClientOptionsReader {
+	return mqtt.ClientOptionsReader{}
+}
+
+func (c *fakeClient) record(call fakeCall) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.calls = append(c.calls, call)
+}
+
+// recorded returns the calls of the op, in order.
+func (c *fakeClient) recorded(op string) []fakeCall {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var calls []fakeCall
+
+	for _, call := range c.calls {
+		if call.op == op {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// deliver sends the payload to the topic's subscriber, it reports if there
+// was one.
+func (c *fakeClient) deliver(topic string, payload []byte) bool {
+	c.mutex.Lock()
+	handler := c.handlers[topic]
+	c.mutex.Unlock()
+
+	if handler == nil {
+		return false
+	}
+
+	handler(c, mockMessage{topic: topic, payload: payload})
+
+	return true
+}
+
+// lose simulates the broker dropping the connection.
+func (c *fakeClient) lose(err error) {
+	c.mutex.Lock()
+	c.connected = false
+	c.handlers = make(map[string]mqtt.MessageHandler)
+	c.mutex.Unlock()
+
+	if c.opts.OnConnectionLost != nil {
+		c.opts.OnConnectionLost(c, err)
+	}
+}
+
+// fakeToken is an mqtt.Token that has completed with err.
+type fakeToken struct {
+	err error
+}
+
+func (fakeToken) Wait() bool                       { return true }
+func (fakeToken) WaitTimeout(_ time.Duration) bool { return true }
+func (fakeToken) Done() <-chan struct{}            { return closedChannel }
+func (t fakeToken) Error() error                   { return t.err }
+
+// newFakeGrill returns a connected Grill on a fakeTransport. It is
+// disconnected when the test ends.
+func newFakeGrill(t *testing.T, opts ...func(*WiFire)) (*Grill, *fakeTransport) {
+	t.Helper()
+
+	ft := &fakeTransport{}
+
+	w, err := New(append([]func(*WiFire){UseTransport(ft)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := w.NewGrill("fake")
+	if err := g.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(g.Disconnect)
+
+	return g, ft
+}
+
+// deliverStatus sends s to the grill's update topic, it fails the test if
+// nothing is subscribed.
+func deliverStatus(t *testing.T, g *Grill, ft *fakeTransport, s Status) {
+	t.Helper()
+
+	if !ft.client().deliver(g.updateTopic(), mockPayload(s)) {
+		t.Fatalf("no subscription to %s", g.updateTopic())
+	}
+}
+
+// eventually fails the test if cond isn't true within a second.
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -1,28 +1,48 @@
 package wifire
 
 import (
+	"encoding/csv"
 	"errors"
 	"fmt"
 	"image/color"
+	"io"
+	"math"
+	"strconv"
 	"time"
 
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/vg"
-	"gonum.org/v1/plot/vg/draw"
 )
 
+// ErrNotEnoughData is returned by Plot when there are fewer than two Status
+// data points.
+var ErrNotEnoughData = errors.New("not enough data to plot")
+
 // PlotterOptions is used to configure the Plotter.
 type PlotterOptions struct {
 	Title            string
+	XLabel           string // default is the Period (e.g. "Hours")
+	YLabel           string // default is "Temperature" with the data's Units
 	Period           Period
 	AmbientColor     color.Color
 	AmbientFillColor color.Color
 	ProbeColor       color.Color
 	GrillColor       color.Color
 	MarkerColor      color.Color
+	BackgroundColor  color.Color
+	GridColor        color.Color
+	TextColor        color.Color // title, labels, and axes
+	StallColor       color.Color
+	HighlightStalls  bool // shade the periods found by DetectStalls
 	Data             []Status
-	Markers          []time.Duration
+	Markers          []Marker
+}
+
+// Marker is an event drawn on the plot as a labeled vertical line.
+type Marker struct {
+	Time  time.Time
+	Label string
 }
 
 // Plotter creates a graph of the wifire Status data.
@@ -35,12 +55,27 @@ type Plotter struct {
 type Period int
 
 // The Period can be hours, minutes, or days. The default is hours.
+// AutoPeriod picks one from the time span of the data.
 const (
 	ByHour Period = iota
 	ByMinute
 	ByDay
+	AutoPeriod
 )
 
+// autoPeriod returns the Period for data spanning d, keeping the number of
+// x-axis units readable.
+func autoPeriod(d time.Duration) Period {
+	switch {
+	case d < 2*time.Hour:
+		return ByMinute
+	case d < 48*time.Hour:
+		return ByHour
+	default:
+		return ByDay
+	}
+}
+
 // NewPlotter returns a Plotter configured with the options o. If o is empty the
 // default settings are used.
 func NewPlotter(o PlotterOptions) *Plotter {
@@ -51,13 +86,20 @@ func NewPlotter(o PlotterOptions) *Plotter {
 			ProbeColor:       color.RGBA{B: 255, A: 255},
 			GrillColor:       color.RGBA{R: 255, A: 255},
 			MarkerColor:      color.RGBA{G: 100, A: 255},
+			BackgroundColor:  color.White,
+			GridColor:        color.Gray{194},
+			TextColor:        color.Black,
+			StallColor:       color.NRGBA{R: 255, G: 200, A: 64},
 		},
 	}
 
 	p.options.Title = o.Title
+	p.options.XLabel = o.XLabel
+	p.options.YLabel = o.YLabel
 	p.options.Period = o.Period
 	p.options.Data = o.Data
 	p.options.Markers = o.Markers
+	p.options.HighlightStalls = o.HighlightStalls
 
 	if o.AmbientColor != nil {
 		p.options.AmbientColor = o.AmbientColor
@@ -75,97 +117,204 @@ func NewPlotter(o PlotterOptions) *Plotter {
 		p.options.GrillColor = o.GrillColor
 	}
 
-	if o.AmbientColor != nil {
+	if o.MarkerColor != nil {
 		p.options.MarkerColor = o.MarkerColor
 	}
 
+	if o.BackgroundColor != nil {
+		p.options.BackgroundColor = o.BackgroundColor
+	}
+
+	if o.GridColor != nil {
+		p.options.GridColor = o.GridColor
+	}
+
+	if o.TextColor != nil {
+		p.options.TextColor = o.TextColor
+	}
+
+	if o.StallColor != nil {
+		p.options.StallColor = o.StallColor
+	}
+
 	return &p
 }
 
+// DarkTheme returns PlotterOptions with colors suited to a dark background.
+// Set the Data and other options on the result before calling NewPlotter.
+func DarkTheme() PlotterOptions {
+	return PlotterOptions{
+		AmbientColor:     color.Gray{90},
+		AmbientFillColor: color.Gray{60},
+		ProbeColor:       color.RGBA{R: 80, G: 160, B: 255, A: 255},
+		GrillColor:       color.RGBA{R: 255, G: 90, B: 70, A: 255},
+		MarkerColor:      color.RGBA{R: 120, G: 220, B: 120, A: 255},
+		BackgroundColor:  color.RGBA{R: 24, G: 26, B: 31, A: 255},
+		GridColor:        color.Gray{70},
+		TextColor:        color.Gray{220},
+		StallColor:       color.NRGBA{R: 200, G: 170, B: 60, A: 64},
+	}
+}
+
 // Plot returns the plot.Plot for the Status data given to the Plotter. The
 // caller should call plot.Save to create the graph files. This allows the
 // caller to define the Plot size and graphics format.
 func (p Plotter) Plot() (*plot.Plot, error) {
-	if p.options.Data == nil {
-		return nil, errors.New("no data")
+	if len(p.options.Data) < 2 {
+		return nil, ErrNotEnoughData
 	}
 
-	ambient := make(plotter.XYs, len(p.options.Data))
+	if p.options.Period == AutoPeriod {
+		elapsed := normalizeStatus(p.options.Data)
+		p.options.Period = autoPeriod(elapsed[len(elapsed)-1])
+	}
 
-	for i, d := range normalizeStatus(p.options.Data) {
-		switch p.options.Period {
-		case ByMinute:
-			ambient[i].X = d.Minutes()
-		case ByHour:
-			ambient[i].X = d.Hours()
-		case ByDay:
-			ambient[i].X = d.Hours() / 24
+	ambient, grill, grillSet, probe, probeSet := p.series()
+
+	p.plot = plot.New()
+	p.plot.Title.Text = p.options.Title
+	p.plot.X.Label.Text = p.xLabel()
+	p.plot.Y.Label.Text = p.yLabel()
+	p.colors()
+
+	if p.options.HighlightStalls { // first so the bands are behind the curves
+		if err := p.stalls(); err != nil {
+			return nil, fmt.Errorf("stalls: %w", err)
 		}
-		ambient[i].Y = float64(p.options.Data[i].Ambient)
 	}
 
-	grill := make(plotter.XYs, len(ambient))
-	probe := make(plotter.XYs, len(ambient))
-	grillSet := make(plotter.XYs, len(ambient))
-	probeSet := make(plotter.XYs, len(ambient))
+	if err := p.ambient(ambient); err != nil {
+		return nil, fmt.Errorf("ambient: %w", err)
+	}
 
-	copy(grill, ambient)
-	copy(probe, ambient)
-	copy(grillSet, ambient)
-	copy(probeSet, ambient)
+	if err := p.grill(grill, grillSet); err != nil {
+		return nil, fmt.Errorf("grill: %w", err)
+	}
 
-	var maxTemp int
+	if hasProbe(p.options.Data) {
+		if err := p.probe(probe, probeSet); err != nil {
+			return nil, fmt.Errorf("probe: %w", err)
+		}
+	}
 
-	for i := range p.options.Data {
-		if p.options.Data[i].Grill > maxTemp {
-			maxTemp = p.options.Data[i].Grill
+	if len(p.options.Markers) > 0 {
+		if err := p.markers(); err != nil {
+			return nil, fmt.Errorf("markers: %w", err)
 		}
-		grill[i].Y = float64(p.options.Data[i].Grill)
-		probe[i].Y = float64(p.options.Data[i].Probe)
-		grillSet[i].Y = float64(p.options.Data[i].Grill)
-		probeSet[i].Y = float64(p.options.Data[i].Probe)
 	}
 
-	markers := make(plotter.XYs, len(p.options.Markers))
+	grid := plotter.NewGrid()
+	grid.Vertical.Color = p.options.GridColor
+	grid.Horizontal.Color = p.options.GridColor
+	p.plot.Add(grid)
 
-	for i, m := range p.options.Markers {
-		switch p.options.Period {
-		case ByMinute:
-			markers[i].X = m.Minutes()
-		case ByHour:
-			markers[i].X = m.Hours()
-		case ByDay:
-			markers[i].X = m.Hours() / 24
+	return p.plot, nil
+}
+
+// series returns the plotted temperatures and set points of the Status data
+// against the elapsed time in the Period's units.
+func (p Plotter) series() (ambient, grill, grillSet, probe, probeSet plotter.XYs) {
+	n := len(p.options.Data)
+	ambient = make(plotter.XYs, n)
+	grill = make(plotter.XYs, n)
+	grillSet = make(plotter.XYs, n)
+	probe = make(plotter.XYs, n)
+	probeSet = make(plotter.XYs, n)
+
+	for i, d := range normalizeStatus(p.options.Data) {
+		x := p.scale(d)
+		s := p.options.Data[i]
+
+		ambient[i] = plotter.XY{X: x, Y: float64(s.Ambient)}
+		grill[i] = plotter.XY{X: x, Y: float64(s.Grill)}
+		grillSet[i] = plotter.XY{X: x, Y: float64(s.GrillSet)}
+		probe[i] = plotter.XY{X: x, Y: float64(s.Probe)}
+		probeSet[i] = plotter.XY{X: x, Y: float64(s.ProbeSet)}
+	}
+
+	return ambient, grill, grillSet, probe, probeSet
+}
+
+// WriteCSV writes the Status data given to the Plotter as CSV with a header
+// row. The elapsed column is the number of seconds since the first Status.
+func (p Plotter) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(CSVHeader()); err != nil {
+		return err
+	}
+
+	for i, d := range normalizeStatus(p.options.Data) {
+		if err := cw.Write(CSVRecord(p.options.Data[i], d)); err != nil {
+			return err
 		}
-		markers[i].Y = float64(maxTemp) / 2 // put markers in the middle of the data
 	}
 
-	p.plot = plot.New()
-	p.plot.Title.Text = p.options.Title
-	p.plot.X.Label.Text = "Hours"
-	p.plot.Y.Label.Text = "Temperature"
+	cw.Flush()
 
-	if err := p.ambient(ambient); err != nil {
-		return nil, fmt.Errorf("ambient: %w", err)
+	return cw.Error()
+}
+
+// CSVHeader returns the header row of the CSV written by WriteCSV.
+func CSVHeader() []string {
+	return []string{"time", "elapsed", "ambient", "grill", "grill_set", "probe", "probe_set"}
+}
+
+// CSVRecord returns the CSV row for s, elapsed is the time since the first
+// Status of the data.
+func CSVRecord(s Status, elapsed time.Duration) []string {
+	return []string{
+		s.Time.Format(time.RFC3339),
+		strconv.FormatFloat(elapsed.Seconds(), 'f', -1, 64),
+		strconv.Itoa(s.Ambient),
+		strconv.Itoa(s.Grill),
+		strconv.Itoa(s.GrillSet),
+		strconv.Itoa(s.Probe),
+		strconv.Itoa(s.ProbeSet),
 	}
+}
 
-	if err := p.grill(grill, grillSet); err != nil {
-		return nil, fmt.Errorf("grill: %w", err)
+// colors applies the background and text colors to the plot.
+func (p *Plotter) colors() {
+	c := p.options.TextColor
+
+	p.plot.BackgroundColor = p.options.BackgroundColor
+	p.plot.Title.TextStyle.Color = c
+	p.plot.Legend.TextStyle.Color = c
+
+	for _, a := range []*plot.Axis{&p.plot.X, &p.plot.Y} {
+		a.Color = c
+		a.Label.TextStyle.Color = c
+		a.Tick.Color = c
+		a.Tick.Label.Color = c
 	}
+}
 
-	if err := p.probe(probe, probeSet); err != nil {
-		return nil, fmt.Errorf("probe: %w", err)
+func (p *Plotter) xLabel() string {
+	if p.options.XLabel != "" {
+		return p.options.XLabel
 	}
 
-	if len(markers) > 0 {
-		if err := p.markers(markers); err != nil {
-			return nil, fmt.Errorf("markers: %w", err)
-		}
+	switch p.options.Period {
+	case ByMinute:
+		return "Minutes"
+	case ByDay:
+		return "Days"
+	default:
+		return "Hours"
 	}
+}
 
-	p.plot.Add(plotter.NewGrid())
+func (p *Plotter) yLabel() string {
+	if p.options.YLabel != "" {
+		return p.options.YLabel
+	}
 
-	return p.plot, nil
+	if len(p.options.Data) == 0 {
+		return "Temperature"
+	}
+
+	return "Temperature (" + p.options.Data[0].Units.String() + ")"
 }
 
 func (p *Plotter) ambient(data plotter.XYs) error {
@@ -212,6 +361,7 @@ func (p *Plotter) grill(actual, set plotter.XYs) error {
 	s.Color = p.options.GrillColor
 	s.LineStyle.Dashes = []vg.Length{vg.Points(1), vg.Points(5)}
 	p.plot.Add(s)
+	p.plot.Legend.Add("grill set", s)
 
 	return nil
 }
@@ -242,29 +392,134 @@ func (p *Plotter) probe(actual, set plotter.XYs) error {
 	s.Color = p.options.ProbeColor
 	s.LineStyle.Dashes = []vg.Length{vg.Points(1), vg.Points(5)}
 	p.plot.Add(s)
+	p.plot.Legend.Add("probe set", s)
 
 	return nil
 }
 
-func (p *Plotter) markers(marks plotter.XYs) error {
-	if marks == nil {
-		return nil // markers are optional
+// markers draws each Marker as a vertical line spanning the plotted data
+// with its label at the top.
+func (p *Plotter) markers() error {
+	lo, hi := p.plot.Y.Min, p.plot.Y.Max // the range of the data already added
+	t0 := p.options.Data[0].Time
+
+	var labels plotter.XYLabels
+
+	for i, m := range p.options.Markers {
+		x := p.scale(m.Time.Sub(t0))
+
+		line, err := plotter.NewLine(plotter.XYs{{X: x, Y: lo}, {X: x, Y: hi}})
+		if err != nil {
+			return err
+		}
+
+		line.Color = p.options.MarkerColor
+		p.plot.Add(line)
+
+		if i == 0 {
+			p.plot.Legend.Add("events", line)
+		}
+
+		if m.Label != "" {
+			labels.XYs = append(labels.XYs, plotter.XY{X: x, Y: hi})
+			labels.Labels = append(labels.Labels, m.Label)
+		}
+	}
+
+	if len(labels.Labels) == 0 {
+		return nil
 	}
 
-	m, err := plotter.NewScatter(marks)
+	l, err := plotter.NewLabels(labels)
 	if err != nil {
 		return err
 	}
 
-	m.GlyphStyle.Shape = draw.CrossGlyph{}
-	m.GlyphStyle.Radius = vg.Points(4)
-	m.Color = p.options.MarkerColor
-	p.plot.Add(m)
-	p.plot.Legend.Add("events", m)
+	l.Offset = vg.Point{X: vg.Points(2), Y: vg.Points(-10)}
+	p.plot.Add(l)
 
 	return nil
 }
 
+// stalls shades each period found by DetectStalls with a vertical band
+// spanning the temperature range of the data.
+func (p *Plotter) stalls() error {
+	lo, hi := tempRange(p.options.Data)
+
+	for i, r := range p.stallRanges() {
+		x0, x1 := r[0], r[1]
+
+		band, err := plotter.NewPolygon(plotter.XYs{{X: x0, Y: lo}, {X: x1, Y: lo}, {X: x1, Y: hi}, {X: x0, Y: hi}})
+		if err != nil {
+			return err
+		}
+
+		band.Color = p.options.StallColor
+		band.LineStyle.Width = 0
+
+		p.plot.Add(band)
+
+		if i == 0 {
+			p.plot.Legend.Add("stall", band)
+		}
+	}
+
+	return nil
+}
+
+// stallRanges returns the start and end X of each period found by
+// DetectStalls.
+func (p *Plotter) stallRanges() [][2]float64 {
+	var ranges [][2]float64
+
+	t0 := p.options.Data[0].Time
+
+	for _, e := range DetectStalls(p.options.Data) {
+		ranges = append(ranges, [2]float64{
+			p.scale(e.Time.Sub(t0)),
+			p.scale(e.Time.Add(e.Duration).Sub(t0)),
+		})
+	}
+
+	return ranges
+}
+
+// tempRange returns the lowest and highest temperatures in the Status data,
+// including zero since the ambient fill extends to it.
+func tempRange(s []Status) (lo, hi float64) {
+	for i := range s {
+		for _, v := range []int{s[i].Ambient, s[i].Grill, s[i].GrillSet, s[i].Probe, s[i].ProbeSet} {
+			lo = math.Min(lo, float64(v))
+			hi = math.Max(hi, float64(v))
+		}
+	}
+
+	return lo, hi
+}
+
+// scale converts the elapsed time d to the X axis units of the Period.
+func (p *Plotter) scale(d time.Duration) float64 {
+	switch p.options.Period {
+	case ByMinute:
+		return d.Minutes()
+	case ByDay:
+		return d.Hours() / 24
+	default:
+		return d.Hours()
+	}
+}
+
+// hasProbe returns true if a probe was connected for any of the Status data.
+func hasProbe(s []Status) bool {
+	for i := range s {
+		if s[i].ProbeConnected {
+			return true
+		}
+	}
+
+	return false
+}
+
 func normalizeStatus(s []Status) []time.Duration {
 	if len(s) == 0 {
 		return nil
@@ -0,0 +1,318 @@
+package wifire
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"gonum.org/v1/plot"
+)
+
+// withProbe returns history with the probe connected and rising a degree a
+// minute towards set.
+func withProbe(history []Status, set int) []Status {
+	for i := range history {
+		history[i].ProbeConnected = true
+		history[i].Probe = 100 + i
+		history[i].ProbeSet = set
+	}
+
+	return history
+}
+
+// renderSVG returns the plot drawn as SVG.
+func renderSVG(t *testing.T, p *plot.Plot) string {
+	t.Helper()
+
+	wt, err := p.WriterTo(400, 200, "svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := wt.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+func TestPlotWithoutProbe(t *testing.T) {
+	data := grillSeries(225, 200, 210, 220, 225)
+
+	if hasProbe(data) {
+		t.Error("hasProbe true without a probe")
+	}
+
+	p, err := NewPlotter(PlotterOptions{Data: data}).Plot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svg := renderSVG(t, p)
+
+	if strings.Contains(svg, ">probe<") || strings.Contains(svg, ">probe set<") {
+		t.Error("probe plotted without a probe")
+	}
+
+	if !strings.Contains(svg, ">grill<") {
+		t.Error("grill not plotted")
+	}
+}
+
+func TestPlotWithProbe(t *testing.T) {
+	data := withProbe(grillSeries(225, 200, 210, 220, 225), 200)
+	data[0].ProbeConnected = false // connected part way through
+
+	if !hasProbe(data) {
+		t.Error("hasProbe false with a probe")
+	}
+
+	p, err := NewPlotter(PlotterOptions{Data: data, Period: ByMinute}).Plot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if svg := renderSVG(t, p); !strings.Contains(svg, ">probe<") || !strings.Contains(svg, ">probe set<") {
+		t.Error("probe not plotted")
+	}
+}
+
+func TestPlotLabels(t *testing.T) {
+	celsius := grillSeries(107, 100, 107)
+	for i := range celsius {
+		celsius[i].Units = Celsius
+	}
+
+	tests := []struct {
+		name string
+		o    PlotterOptions
+		x, y string
+	}{
+		{"hours", PlotterOptions{Period: ByHour, Data: grillSeries(225, 200, 225)}, "Hours", "Temperature (°F)"},
+		{"minutes", PlotterOptions{Period: ByMinute, Data: grillSeries(225, 200, 225)}, "Minutes", "Temperature (°F)"},
+		{"days", PlotterOptions{Period: ByDay, Data: grillSeries(225, 200, 225)}, "Days", "Temperature (°F)"},
+		{"celsius", PlotterOptions{Data: celsius}, "Hours", "Temperature (°C)"},
+		{"overrides", PlotterOptions{XLabel: "Time", YLabel: "Heat", Data: grillSeries(225, 200, 225)}, "Time", "Heat"},
+	}
+
+	for _, tt := range tests {
+		p, err := NewPlotter(tt.o).Plot()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.X.Label.Text != tt.x || p.Y.Label.Text != tt.y {
+			t.Errorf("%s: got %q and %q, want %q and %q", tt.name, p.X.Label.Text, p.Y.Label.Text, tt.x, tt.y)
+		}
+	}
+}
+
+func TestPlotSeries(t *testing.T) {
+	data := withProbe(grillSeries(225, 150, 200, 230), 203)
+
+	_, grill, grillSet, probe, probeSet := NewPlotter(PlotterOptions{Period: ByMinute, Data: data}).series()
+
+	for i := range data {
+		if grill[i].X != float64(i) || grillSet[i].X != grill[i].X || probeSet[i].X != probe[i].X {
+			t.Errorf("%d: X of %g, %g, %g, %g, want %d", i, grill[i].X, grillSet[i].X, probe[i].X, probeSet[i].X, i)
+		}
+
+		if grill[i].Y != float64(data[i].Grill) || grillSet[i].Y != 225 {
+			t.Errorf("%d: grill %g set %g", i, grill[i].Y, grillSet[i].Y)
+		}
+
+		if probe[i].Y != float64(data[i].Probe) || probeSet[i].Y != 203 {
+			t.Errorf("%d: probe %g set %g", i, probe[i].Y, probeSet[i].Y)
+		}
+	}
+}
+
+func TestPlotMarkers(t *testing.T) {
+	data := grillSeries(225, 200, 210, 220, 225)
+	markers := []Marker{{Time: t0.Add(90 * time.Minute), Label: "wrapped"}}
+
+	tests := []struct {
+		period Period
+		want   float64
+	}{
+		{ByMinute, 90},
+		{ByHour, 1.5},
+		{ByDay, 1.5 / 24},
+	}
+
+	for _, tt := range tests {
+		p, err := NewPlotter(PlotterOptions{Data: data, Period: tt.period, Markers: markers}).Plot()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The marker is past the data so it sets the end of the X axis.
+		if p.X.Max != tt.want {
+			t.Errorf("period %d: marker at X %g, want %g", tt.period, p.X.Max, tt.want)
+		}
+
+		if svg := renderSVG(t, p); !strings.Contains(svg, ">wrapped<") || !strings.Contains(svg, ">events<") {
+			t.Errorf("period %d: marker label or legend missing", tt.period)
+		}
+	}
+}
+
+func TestPlotColors(t *testing.T) {
+	data := grillSeries(225, 200, 210, 220, 225)
+
+	p, err := NewPlotter(PlotterOptions{Data: data}).Plot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.BackgroundColor != color.White || p.Title.TextStyle.Color != color.Black {
+		t.Errorf("default background %v text %v", p.BackgroundColor, p.Title.TextStyle.Color)
+	}
+
+	dark := DarkTheme()
+	dark.Data = data
+
+	if p, err = NewPlotter(dark).Plot(); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.BackgroundColor != dark.BackgroundColor {
+		t.Errorf("dark background %v, want %v", p.BackgroundColor, dark.BackgroundColor)
+	}
+
+	for _, c := range []color.Color{p.Title.TextStyle.Color, p.X.Label.TextStyle.Color, p.Y.Tick.Label.Color, p.Legend.TextStyle.Color} {
+		if c != dark.TextColor {
+			t.Errorf("dark text %v, want %v", c, dark.TextColor)
+		}
+	}
+
+	custom := color.RGBA{R: 1, G: 2, B: 3, A: 255}
+
+	if p, err = NewPlotter(PlotterOptions{Data: data, BackgroundColor: custom}).Plot(); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.BackgroundColor != custom || p.Title.TextStyle.Color != color.Black {
+		t.Errorf("custom background %v text %v", p.BackgroundColor, p.Title.TextStyle.Color)
+	}
+}
+
+func TestPlotStalls(t *testing.T) {
+	// The probe climbs a degree a minute, holds at 130 from 30m to 70m, then
+	// climbs again.
+	data := grillSeries(225, make([]int, 90)...)
+
+	for i := range data {
+		data[i].Ambient = 70
+		data[i].Grill = 225
+		data[i].ProbeConnected = true
+		data[i].ProbeSet = 200
+
+		switch {
+		case i < 30:
+			data[i].Probe = 100 + i
+		case i < 70:
+			data[i].Probe = 130
+		default:
+			data[i].Probe = 130 + i - 70
+		}
+	}
+
+	p := NewPlotter(PlotterOptions{Data: data, Period: ByMinute, HighlightStalls: true})
+
+	// Within 2 degrees from 28m until the window past 72m rises 3.
+	if got := p.stallRanges(); len(got) != 1 || got[0] != [2]float64{28, 72} {
+		t.Fatalf("stall ranges %v, want [[28 72]]", got)
+	}
+
+	plot, err := p.Plot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if svg := renderSVG(t, plot); !strings.Contains(svg, ">stall<") {
+		t.Error("stall not in the legend")
+	}
+
+	p = NewPlotter(PlotterOptions{Data: grillSeries(225, 200, 210, 220, 225), HighlightStalls: true})
+	if got := p.stallRanges(); len(got) != 0 {
+		t.Errorf("stall ranges %v without a probe", got)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	data := withProbe(grillSeries(225, 200, 210, 220), 203)
+
+	var buf bytes.Buffer
+	if err := NewPlotter(PlotterOptions{Data: data}).WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != len(data)+1 {
+		t.Fatalf("%d rows, want a header and %d", len(rows), len(data))
+	}
+
+	if got := strings.Join(rows[0], ","); got != "time,elapsed,ambient,grill,grill_set,probe,probe_set" {
+		t.Errorf("header %q", got)
+	}
+
+	if got := strings.Join(rows[2], ","); got != "2024-07-04T12:01:00Z,60,0,210,225,101,203" {
+		t.Errorf("second row %q", got)
+	}
+}
+
+func TestPlotNotEnoughData(t *testing.T) {
+	for n := 0; n < 3; n++ {
+		_, err := NewPlotter(PlotterOptions{Data: grillSeries(225, make([]int, n)...)}).Plot()
+
+		if n < 2 && !errors.Is(err, ErrNotEnoughData) {
+			t.Errorf("%d: got %v, want ErrNotEnoughData", n, err)
+		}
+
+		if n == 2 && err != nil {
+			t.Errorf("%d: %v", n, err)
+		}
+	}
+}
+
+func TestAutoPeriod(t *testing.T) {
+	tests := []struct {
+		span time.Duration
+		want Period
+	}{
+		{0, ByMinute},
+		{90 * time.Minute, ByMinute},
+		{2 * time.Hour, ByHour},
+		{12 * time.Hour, ByHour},
+		{48 * time.Hour, ByDay},
+		{72 * time.Hour, ByDay},
+	}
+
+	for _, tt := range tests {
+		if got := autoPeriod(tt.span); got != tt.want {
+			t.Errorf("autoPeriod(%s) = %d, want %d", tt.span, got, tt.want)
+		}
+	}
+
+	data := grillSeries(225, 200, 225)
+	data[1].Time = t0.Add(6 * time.Hour)
+
+	p, err := NewPlotter(PlotterOptions{Data: data, Period: AutoPeriod}).Plot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.X.Label.Text != "Hours" || p.X.Max != 6 {
+		t.Errorf("6h plotted with label %q to %g", p.X.Label.Text, p.X.Max)
+	}
+}
@@ -1,42 +1,447 @@
 package wifire
 
-import mqtt "github.com/eclipse/paho.mqtt.golang"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Errors returned by the Grill MQTT connection. The underlying MQTT error is
+// wrapped so both can be tested with errors.Is.
+var (
+	// ErrNotConnected is returned when a Grill method needs a connection but
+	// Connect has not been called.
+	ErrNotConnected = errors.New("grill is not connected")
+	// ErrMQTTConnect is returned when the connection to the MQTT broker
+	// fails.
+	ErrMQTTConnect = errors.New("mqtt connect failed")
+	// ErrMQTTSubscribe is returned when a subscription is refused.
+	ErrMQTTSubscribe = errors.New("mqtt subscribe failed")
+	// ErrMQTTExpired is returned when the connection was lost after its
+	// credentials expired and could not be renewed. Connect again to get new
+	// credentials.
+	ErrMQTTExpired = errors.New("mqtt credentials expired")
+)
 
 // Grill is a handle for a grills MQTT connection.
 type Grill struct {
-	name   string
-	wifire WiFire
-	client mqtt.Client
+	name      string
+	model     GrillModel
+	wifire    *WiFire
+	mutex     sync.RWMutex
+	client    mqtt.Client // guarded by mutex, use mqttClient to read
+	update    sync.Mutex  // serializes the update topic subscribe and unsubscribe
+	status    *subscriber[Status]
+	usage     *subscriber[Usage]
+	events    *subscriber[Event]
+	fanout    []*subscriber[Status] // from AddSubscriber
+	waiting   []*subscriber[Status] // from watch, for Status and WaitForTarget
+	derive    EventDetector         // for events, guarded by mutex
+	cookStart time.Time             // of the current cook, zero when not cooking
+	last      Status                // most recent valid Status received
+	renew     *time.Timer           // renews the connection before it expires
+	expires   time.Time             // of the client credentials, zero if they don't
+	state     ConnState
+	changes   []func(ConnState) // from OnConnectionChange
+	unknown   sync.Once         // logs an unrecognized model once
+
+	// subscriptions are the active topic subscriptions, these are restored
+	// after a reconnect.
+	subscriptions map[string]mqtt.MessageHandler
 }
 
 // NewGrill returns a Grill with the given name.
-func (w WiFire) NewGrill(name string) *Grill {
-	return &Grill{
+func (w *WiFire) NewGrill(name string) *Grill {
+	g := Grill{
 		name:   name,
 		wifire: w,
 	}
+
+	w.mutex.Lock()
+	for i := range w.things {
+		if w.things[i].Name == name {
+			g.model = w.things[i].GrillModel
+		}
+	}
+	w.grills = append(w.grills, &g)
+	w.mutex.Unlock()
+
+	return &g
+}
+
+// Name returns the name of the grill, the thing name from UserData.
+func (g *Grill) Name() string {
+	return g.name
+}
+
+// Model returns the GrillModel of the grill. This is only known if UserData
+// was called before NewGrill, otherwise it is empty.
+func (g *Grill) Model() GrillModel {
+	return g.model
 }
 
 // Connect establishes the MQTT connection to the Grill.
 func (g *Grill) Connect() error {
-	client, err := g.wifire.getMQTT()
+	return g.ConnectContext(context.Background())
+}
+
+// subscriber is a channel receiving updates from an MQTT subscription. Sends
+// never block the MQTT client: if the channel is full the oldest queued
+// update is dropped to make room, and with an unbuffered channel an update
+// is dropped if the receiver is not ready. The channel is closed when it is
+// unsubscribed.
+type subscriber[T any] struct {
+	mutex   sync.Mutex // held while sending so the channel isn't closed mid-send
+	ch      chan T
+	closed  bool
+	dropped atomic.Int64
+}
+
+func newSubscriber[T any](ch chan T) *subscriber[T] {
+	return &subscriber[T]{ch: ch}
+}
+
+func (s *subscriber[T]) send(v T) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	for {
+		select {
+		case s.ch <- v:
+			return
+		default:
+		}
+
+		if cap(s.ch) == 0 {
+			s.drop()
+			return
+		}
+
+		select {
+		case <-s.ch:
+			s.drop()
+		default: // the receiver made room
+		}
+	}
+}
+
+func (s *subscriber[T]) drop() {
+	logf(LogWarn, "slow subscriber, %d updates dropped", s.dropped.Add(1))
+}
+
+// stop closes the channel, it is safe to call more than once.
+func (s *subscriber[T]) stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// subscribeChannel makes ch the subscriber in slot, closing the channel of
+// any previous one, and subscribes to the update topic. Subscribing again
+// with the same channel only renews the topic subscription. If the
+// subscription fails the slot is cleared without closing ch, the caller still
+// owns it.
+func subscribeChannel[T any](g *Grill, slot **subscriber[T], ch chan T) error {
+	g.mutex.Lock()
+	prev := *slot
+	sub := prev
+	if prev == nil || prev.ch != ch {
+		sub = newSubscriber(ch)
+		*slot = sub
+	}
+	g.mutex.Unlock()
+
+	if prev != nil && prev != sub {
+		prev.stop()
+	}
+
+	if err := g.subscribeUpdate(context.Background()); err != nil {
+		g.mutex.Lock()
+		if *slot == sub {
+			*slot = nil
+		}
+		g.mutex.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+// unsubscribeChannel clears the subscriber in slot, closing its channel. The
+// update topic is shared so it is only unsubscribed once nothing uses it.
+func unsubscribeChannel[T any](g *Grill, slot **subscriber[T]) error {
+	g.mutex.Lock()
+	stop := takeSubscriber(slot)
+	g.mutex.Unlock()
+
+	if stop == nil {
+		return nil
+	}
+
+	stop()
+
+	return g.unsubscribeUpdate()
+}
+
+// takeSubscriber clears the slot and returns the stop func of the subscriber
+// that was in it, or nil if it was empty. The caller must hold the mutex.
+func takeSubscriber[T any](slot **subscriber[T]) func() {
+	sub := *slot
+	*slot = nil
+
+	if sub == nil {
+		return nil
+	}
+
+	return sub.stop
+}
+
+// ConnectContext is like Connect but gives up if ctx is canceled. The
+// connection is renewed shortly before its credentials expire. Connecting a
+// Grill that is already connected replaces its connection, the subscriptions
+// are kept.
+func (g *Grill) ConnectContext(ctx context.Context) error {
+	client, expires, err := g.wifire.config.transport.MQTTClient(ctx, g.handlers)
 	if err != nil {
 		return err
 	}
 
+	g.mutex.Lock()
+	old := g.client
 	g.client = client
-	return g.connect()
+	g.expires = expires
+
+	if g.renew != nil {
+		g.renew.Stop()
+		g.renew = nil
+	}
+	g.mutex.Unlock()
+
+	if old != nil {
+		old.Disconnect(250)
+	}
+
+	g.setState(nil, Connecting)
+
+	if err := wait(ctx, client.Connect()); err != nil {
+		g.setState(nil, Disconnected)
+		return fmt.Errorf("%w: %w", ErrMQTTConnect, err)
+	}
+
+	g.scheduleRenew(expires)
+
+	return nil
 }
 
-// Disconnect closed the MQTT connection to the Grill.
-func (g Grill) Disconnect() {
-	g.client.Disconnect(0)
+// Disconnect closes the MQTT connection to the Grill and ends the
+// subscriptions, closing their channels. It is safe to call Disconnect on a
+// Grill that is not connected.
+func (g *Grill) Disconnect() {
+	g.mutex.Lock()
+	client := g.client
+	stops := []func(){
+		takeSubscriber(&g.status),
+		takeSubscriber(&g.usage),
+		takeSubscriber(&g.events),
+	}
+	for _, sub := range append(slices.Clip(g.fanout), g.waiting...) {
+		stops = append(stops, sub.stop)
+	}
+	g.client = nil
+	g.fanout = nil
+	g.waiting = nil
+	g.subscriptions = nil
+
+	if g.renew != nil {
+		g.renew.Stop()
+		g.renew = nil
+	}
+	g.mutex.Unlock()
+
+	if client != nil {
+		client.Disconnect(0)
+	}
+
+	for _, stop := range stops {
+		if stop != nil {
+			stop()
+		}
+	}
+
+	g.setState(nil, Disconnected)
+}
+
+// scheduleRenew arranges for the connection to be renewed mqttRefreshSkew
+// before expires. A zero expires, or a disconnected Grill, is never renewed.
+func (g *Grill) scheduleRenew(expires time.Time) {
+	if expires.IsZero() {
+		return
+	}
+
+	d := max(time.Until(expires)-mqttRefreshSkew, time.Minute) // don't spin on a short expiry
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.client == nil {
+		return
+	}
+
+	if g.renew != nil {
+		g.renew.Stop()
+	}
+	g.renew = time.AfterFunc(d, g.renewConnection)
+}
+
+// renewConnection replaces the MQTT client with one using new credentials
+// before the current ones expire. The subscriptions are restored by
+// onConnect. If the renewal fails the current client is kept and the
+// renewal is tried again later.
+func (g *Grill) renewConnection() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	logf(LogInfo, "renewing MQTT connection for %s", g.name)
+
+	client, expires, err := g.wifire.config.transport.MQTTClient(ctx, g.handlers)
+	if err == nil {
+		err = wait(ctx, client.Connect())
+	}
+
+	if err != nil {
+		logf(LogWarn, "cannot renew MQTT connection: %s", err)
+		g.scheduleRenew(time.Now().Add(mqttRefreshSkew + time.Minute))
+
+		return
+	}
+
+	g.mutex.Lock()
+	old := g.client
+	if old != nil { // not disconnected while renewing
+		g.client = client
+		g.expires = expires
+	}
+	g.mutex.Unlock()
+
+	if old == nil {
+		client.Disconnect(0)
+		return
+	}
+
+	old.Disconnect(250)
+	g.scheduleRenew(expires)
+}
+
+// mqttClient returns the current MQTT client, making sure it is connected.
+// The returned client is a copy that is safe to use without holding the
+// mutex.
+func (g *Grill) mqttClient(ctx context.Context) (mqtt.Client, error) {
+	g.mutex.RLock()
+	client, expires := g.client, g.expires
+	g.mutex.RUnlock()
+
+	if client == nil {
+		return nil, ErrNotConnected
+	}
+
+	if !client.IsConnected() {
+		if !expires.IsZero() && time.Now().After(expires) {
+			return nil, ErrMQTTExpired
+		}
+
+		if err := wait(ctx, client.Connect()); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMQTTConnect, err)
+		}
+	}
+
+	return client, nil
 }
 
-func (g Grill) connect() error {
-	if token := g.client.Connect(); token.Wait() && token.Error() != nil {
-		return token.Error()
+// subscribeRefused is the SUBACK return code for a refused subscription.
+const subscribeRefused = 0x80
+
+// subscribe subscribes to the topic and records the subscription so it is
+// restored after a reconnect.
+func (g *Grill) subscribe(ctx context.Context, topic string, handler mqtt.MessageHandler) error {
+	client, err := g.mqttClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	t := client.Subscribe(topic, g.wifire.config.qos, handler)
+
+	if err := wait(ctx, t); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrMQTTSubscribe, topic, err)
 	}
 
+	// The broker reports a refused subscription in the SUBACK, not as an
+	// error.
+	if st, ok := t.(*mqtt.SubscribeToken); ok && st.Result()[topic] == subscribeRefused {
+		return fmt.Errorf("%w: %s: refused by broker", ErrMQTTSubscribe, topic)
+	}
+
+	g.mutex.Lock()
+	if g.subscriptions == nil {
+		g.subscriptions = make(map[string]mqtt.MessageHandler)
+	}
+	g.subscriptions[topic] = handler
+	g.mutex.Unlock()
+
 	return nil
 }
+
+// handlers sets the Grill's connection handlers in the MQTT client options.
+func (g *Grill) handlers(opts *mqtt.ClientOptions) {
+	auto := opts.AutoReconnect
+
+	opts.OnConnect = g.onConnect
+	opts.OnConnectionLost = func(c mqtt.Client, err error) {
+		connectionLost(c, err)
+
+		if auto {
+			g.setState(c, Reconnecting)
+		} else {
+			g.setState(c, Disconnected)
+		}
+	}
+	opts.OnReconnecting = func(c mqtt.Client, opts *mqtt.ClientOptions) {
+		reconnecting(c, opts)
+		g.setState(c, Reconnecting)
+	}
+}
+
+// onConnect is called on the initial connect and after every automatic
+// reconnect. The broker does not keep the subscriptions of a clean session so
+// they are re-issued here.
+func (g *Grill) onConnect(c mqtt.Client) {
+	connect(c)
+	g.setState(c, Connected)
+
+	g.mutex.RLock()
+	subs := make(map[string]mqtt.MessageHandler, len(g.subscriptions))
+	for topic, handler := range g.subscriptions {
+		subs[topic] = handler
+	}
+	g.mutex.RUnlock()
+
+	for topic, handler := range subs {
+		if err := wait(context.Background(), c.Subscribe(topic, g.wifire.config.qos, handler)); err != nil {
+			logf(LogError, "cannot resubscribe to %s: %s", topic, err)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package wifire
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockData returns n Status a second apart.
+func mockData(n int) []Status {
+	data := make([]Status, n)
+	for i := range data {
+		data[i] = Status{
+			Time:         t0.Add(time.Duration(i) * time.Second),
+			Grill:        200 + i,
+			GrillSet:     225,
+			SystemStatus: StatusManualCook,
+			Units:        Fahrenheit,
+		}
+	}
+
+	return data
+}
+
+func TestConcurrentSubscribeDisconnect(t *testing.T) {
+	w, err := New(UseTransport(&MockTransport{Data: mockData(1000), Interval: 100 * time.Microsecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := w.NewGrill("mock")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				ch := make(chan Status, 1)
+				_ = g.SubscribeStatus(ch) // fails while disconnected
+				_ = g.UnsubscribeStatus()
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				ch := make(chan Status)
+				_ = g.AddSubscriber(ch)
+				_ = g.RemoveSubscriber(ch)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				_ = g.Connect()
+				_ = g.ConnectionState()
+				g.Disconnect()
+			}
+		}()
+	}
+
+	wg.Wait()
+	g.Disconnect()
+}
+
+func TestResubscribeOnReconnect(t *testing.T) {
+	g, ft := newFakeGrill(t)
+
+	if err := g.SubscribeStatus(make(chan Status, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ft.client()
+	c.lose(errors.New("connection reset"))
+
+	if err := c.Connect().Error(); err != nil { // as the automatic reconnect does
+		t.Fatal(err)
+	}
+
+	if calls := c.recorded("subscribe"); len(calls) != 2 || calls[1].topic != "prod/thing/update/fake" {
+		t.Errorf("subscribed to %v, want prod/thing/update/fake twice", calls)
+	}
+
+	if !c.deliver("prod/thing/update/fake", mockPayload(Status{Time: t0})) {
+		t.Error("update handler not restored")
+	}
+}
+
+func TestQoS(t *testing.T) {
+	for _, qos := range []byte{0, 1, 2} {
+		g, ft := newFakeGrill(t, QoS(qos))
+
+		ch := make(chan Status, 1)
+		if err := g.SubscribeStatus(ch); err != nil {
+			t.Fatal(err)
+		}
+
+		deliverStatus(t, g, ft, Status{Time: t0, Units: Fahrenheit})
+		<-ch
+
+		if err := g.SetTemperature(context.Background(), 225); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, op := range []string{"subscribe", "publish"} {
+			if calls := ft.client().recorded(op); len(calls) != 1 || calls[0].qos != qos {
+				t.Errorf("%s with %+v, want QoS %d", op, calls, qos)
+			}
+		}
+	}
+}
+
+func TestModel(t *testing.T) {
+	api := newFakeAPI(t)
+
+	w, err := New(api.options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m := w.NewGrill("grill").Model(); m.Name != "" {
+		t.Errorf("model %q known before UserData", m.Name)
+	}
+
+	if _, err := w.UserData(); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := w.NewGrill("grill").Model(); m.Name != "Ironwood 885" {
+		t.Errorf("got model %q, want Ironwood 885", m.Name)
+	}
+}
+
+func TestFirmware(t *testing.T) {
+	s := newUpdate([]byte(`{"settings":{"fw_version":"2.03.13"},"status":{"time":1720094400}}`))
+
+	if s.Firmware != "2.03.13" {
+		t.Errorf("got firmware %q, want 2.03.13", s.Firmware)
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	g, ft := newFakeGrill(t)
+
+	ch := make(chan Status, 2)
+	if err := g.SubscribeStatus(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing reads ch until every update has been delivered.
+	start := time.Now()
+
+	for i := 0; i < 100; i++ {
+		deliverStatus(t, g, ft, Status{Grill: 100 + i, Units: Fahrenheit})
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("delivering to a slow subscriber took %s", elapsed)
+	}
+
+	if got := g.status.dropped.Load(); got != 98 {
+		t.Errorf("%d updates dropped, want 98", got)
+	}
+
+	// Only the newest updates are left.
+	if a, b := <-ch, <-ch; a.Grill != 198 || b.Grill != 199 {
+		t.Errorf("got grill %d and %d, want 198 and 199", a.Grill, b.Grill)
+	}
+}
+
+func TestRenewConnection(t *testing.T) {
+	ft := &fakeTransport{expires: time.Now().Add(mqttRefreshSkew + time.Second)}
+
+	w, err := New(UseTransport(ft))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := w.NewGrill("fake")
+	if err := g.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer g.Disconnect()
+
+	g.mutex.RLock()
+	scheduled := g.renew != nil
+	g.mutex.RUnlock()
+
+	if !scheduled {
+		t.Fatal("renewal not scheduled for expiring credentials")
+	}
+
+	ch := make(chan Status, 1)
+	if err := g.SubscribeStatus(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	old := ft.client()
+
+	g.renewConnection() // as the timer does shortly before expiry
+
+	renewed := ft.client()
+	if renewed == old {
+		t.Fatal("no new client")
+	}
+
+	if old.IsConnected() || !renewed.IsConnected() {
+		t.Errorf("old connected %t, renewed connected %t", old.IsConnected(), renewed.IsConnected())
+	}
+
+	if calls := renewed.recorded("subscribe"); len(calls) != 1 || calls[0].topic != g.updateTopic() {
+		t.Errorf("renewed client subscribed to %v, want %s", calls, g.updateTopic())
+	}
+
+	deliverStatus(t, g, ft, Status{Grill: 225, Time: t0})
+
+	if s := <-ch; s.Grill != 225 {
+		t.Errorf("got grill %d, want 225", s.Grill)
+	}
+}
+
+func TestConnectAgain(t *testing.T) {
+	ft := &fakeTransport{expires: time.Now().Add(mqttRefreshSkew + time.Hour)}
+
+	w, err := New(UseTransport(ft))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := w.NewGrill("fake")
+	if err := g.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer g.Disconnect()
+
+	ch := make(chan Status, 1)
+	if err := g.SubscribeStatus(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	g.mutex.RLock()
+	timer := g.renew
+	g.mutex.RUnlock()
+
+	old := ft.client()
+
+	if err := g.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if old.IsConnected() {
+		t.Error("old client left connected")
+	}
+
+	if timer.Stop() {
+		t.Error("old renewal left scheduled")
+	}
+
+	if calls := ft.client().recorded("subscribe"); len(calls) != 1 || calls[0].topic != g.updateTopic() {
+		t.Errorf("new client subscribed to %v, want %s", calls, g.updateTopic())
+	}
+
+	deliverStatus(t, g, ft, Status{Grill: 225, Time: t0})
+
+	if s := <-ch; s.Grill != 225 {
+		t.Errorf("got grill %d, want 225", s.Grill)
+	}
+}
+
+func TestNoRenewWithoutExpiry(t *testing.T) {
+	g, _ := newFakeGrill(t)
+
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	if g.renew != nil {
+		t.Error("renewal scheduled for credentials that never expire")
+	}
+}
+
+func TestMQTTErrors(t *testing.T) {
+	refused := errors.New("connection refused")
+	ft := &fakeTransport{connectErr: refused}
+
+	w, err := New(UseTransport(ft))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := w.NewGrill("fake")
+
+	if err := g.SubscribeStatus(make(chan Status, 1)); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("subscribe before connect: got %v, want ErrNotConnected", err)
+	}
+
+	if err := g.Connect(); !errors.Is(err, ErrMQTTConnect) || !errors.Is(err, refused) {
+		t.Errorf("connect: got %v, want ErrMQTTConnect wrapping %v", err, refused)
+	}
+
+	if s := g.ConnectionState(); s != Disconnected {
+		t.Errorf("state %s after a failed connect", s)
+	}
+
+	g, ft = newFakeGrill(t)
+	ft.subscribeErr = errors.New("timeout")
+
+	err = g.SubscribeStatus(make(chan Status, 1))
+	if !errors.Is(err, ErrMQTTSubscribe) || !errors.Is(err, ft.subscribeErr) || !strings.Contains(err.Error(), g.updateTopic()) {
+		t.Errorf("subscribe: got %v, want ErrMQTTSubscribe for %s", err, g.updateTopic())
+	}
+}
+
+func TestOnConnectionChange(t *testing.T) {
+	ft := &fakeTransport{}
+
+	w, err := New(UseTransport(ft))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := w.NewGrill("fake")
+
+	var (
+		mutex  sync.Mutex
+		states []ConnState
+	)
+
+	g.OnConnectionChange(func(s ConnState) {
+		mutex.Lock()
+		states = append(states, s)
+		mutex.Unlock()
+	})
+
+	if err := g.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	ft.client().lose(errors.New("connection reset"))
+
+	if err := ft.client().Connect().Error(); err != nil { // as the automatic reconnect does
+		t.Fatal(err)
+	}
+
+	g.Disconnect()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	want := []ConnState{Connecting, Connected, Reconnecting, Connected, Disconnected}
+	if !slices.Equal(states, want) {
+		t.Errorf("states %v, want %v", states, want)
+	}
+
+	if s := g.ConnectionState(); s != Disconnected {
+		t.Errorf("ConnectionState %s, want disconnected", s)
+	}
+}
+
+func TestDisconnectEndsRange(t *testing.T) {
+	g, ft := newFakeGrill(t)
+
+	ch := make(chan Status, 1)
+	if err := g.SubscribeStatus(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan int)
+
+	go func() {
+		n := 0
+		for range ch {
+			n++
+		}
+		done <- n
+	}()
+
+	deliverStatus(t, g, ft, Status{Grill: 225, Time: t0})
+	eventually(t, func() bool { return len(ch) == 0 })
+
+	g.Disconnect()
+
+	select {
+	case n := <-done:
+		if n != 1 {
+			t.Errorf("received %d updates, want 1", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("range did not end after Disconnect")
+	}
+
+	g.Disconnect() // again, must not panic
+}
+
+func TestSubscribeRefused(t *testing.T) {
+	g, ft := newFakeGrill(t)
+	ft.subscribeErr = errors.New("refused")
+
+	status := make(chan Status, 1)
+	if err := g.SubscribeStatus(status); !errors.Is(err, ErrMQTTSubscribe) {
+		t.Fatalf("status: got %v, want ErrMQTTSubscribe", err)
+	}
+
+	usage := make(chan Usage, 1)
+	if err := g.SubscribeUsage(usage); !errors.Is(err, ErrMQTTSubscribe) {
+		t.Fatalf("usage: got %v, want ErrMQTTSubscribe", err)
+	}
+
+	status <- Status{} // still open, the caller owns them
+	close(status)
+	usage <- Usage{}
+	close(usage)
+
+	g.Disconnect() // must not close them again
+}
@@ -2,6 +2,7 @@ package wifire
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -30,7 +31,7 @@ func logf(l LogLevel, format string, v ...interface{}) {
 		return
 	}
 
-	Logger(l, "", strings.Trim(fmt.Sprintf(format, v...), "[]"))
+	Logger(l, "", redact(strings.Trim(fmt.Sprintf(format, v...), "[]")))
 }
 
 func logln(l LogLevel, v ...interface{}) {
@@ -45,7 +46,29 @@ func logln(l LogLevel, v ...interface{}) {
 		v = v[1:]
 	}
 
-	Logger(l, comp, strings.Trim(fmt.Sprint(v...), "[]"))
+	Logger(l, comp, redact(strings.Trim(fmt.Sprint(v...), "[]")))
+}
+
+const redacted = "REDACTED"
+
+var (
+	// secretFields are JSON fields holding credentials, tokens, signed
+	// URLs, or personal details.
+	secretFields = regexp.MustCompile(`(?i)("(?:password|idtoken|accesstoken|refreshtoken|refresh_token|signedurl|email|authorization)"\s*:\s*)"[^"]*"`)
+	// signedQuery is the query string of a URL, which holds the signature
+	// of the signed MQTT broker URL.
+	signedQuery = regexp.MustCompile(`((?:https?|wss?)://[^\s"?]+)\?[^\s"]*`)
+	jwt         = regexp.MustCompile(`eyJ[\w-]+\.[\w-]+\.[\w-]*`)
+	email       = regexp.MustCompile(`[\w.+-]+@[\w-]+(?:\.[\w-]+)+`)
+)
+
+// redact masks the secrets in a log message so debug logs are safe to share.
+func redact(msg string) string {
+	msg = secretFields.ReplaceAllString(msg, `$1"`+redacted+`"`)
+	msg = signedQuery.ReplaceAllString(msg, `$1?`+redacted)
+	msg = jwt.ReplaceAllString(msg, redacted)
+
+	return email.ReplaceAllString(msg, redacted)
 }
 
 type (
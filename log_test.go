@@ -0,0 +1,58 @@
+package wifire
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedact(t *testing.T) {
+	token := testJWT(time.Now())
+
+	tests := []struct {
+		in, want string
+	}{
+		{
+			`{"expirationSeconds":3600,"signedUrl":"wss://broker.example/mqtt?X-Amz-Signature=secret"}`,
+			`{"expirationSeconds":3600,"signedUrl":"REDACTED"}`,
+		},
+		{
+			"connecting to wss://broker.example/mqtt?X-Amz-Signature=secret now",
+			"connecting to wss://broker.example/mqtt?REDACTED now",
+		},
+		{
+			`{"AuthParameters":{"USERNAME":"user","PASSWORD":"hunter2"}}`,
+			`{"AuthParameters":{"USERNAME":"user","PASSWORD":"REDACTED"}}`,
+		},
+		{"token " + token + " expired", "token REDACTED expired"},
+		{"logged in as cook@example.com", "logged in as REDACTED"},
+		{"grill temperature 225", "grill temperature 225"},
+	}
+
+	for _, tt := range tests {
+		if got := redact(tt.in); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLogRedacts(t *testing.T) {
+	var logged []string
+
+	prev := Logger
+	Logger = func(_ LogLevel, _, message string) { logged = append(logged, message) }
+	t.Cleanup(func() { Logger = prev })
+
+	logf(LogDebug, "response %s", `{"signedUrl":"wss://broker.example/mqtt?X-Amz-Signature=secret"}`)
+	logln(LogDebug, "[client]", "dialing wss://broker.example/mqtt?X-Amz-Signature=secret")
+
+	for _, msg := range logged {
+		if strings.Contains(msg, "secret") {
+			t.Errorf("signature logged: %s", msg)
+		}
+	}
+
+	if len(logged) != 2 {
+		t.Errorf("%d messages logged, want 2", len(logged))
+	}
+}
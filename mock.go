@@ -0,0 +1,297 @@
+package wifire
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MockTransport is a Transport that replays recorded Status data without an
+// account or network connection. The account has a single grill and every
+// status subscription receives the Data in order, one Status per Interval.
+// If Speed is set the recorded time between each Status divided by Speed is
+// used instead of the Interval. Commands published to the grill are ignored.
+type MockTransport struct {
+	Name     string        // grill name, the default is "mock"
+	Data     []Status      // replayed to status subscriptions
+	Interval time.Duration // between each Status, the default is one second
+	Speed    float64       // replay the recorded time faster by this factor
+}
+
+// NewMockTransport returns a MockTransport replaying the newline delimited
+// JSON Status records read from r.
+func NewMockTransport(r io.Reader) (*MockTransport, error) {
+	var t MockTransport
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		var status Status
+
+		if err := json.Unmarshal(s.Bytes(), &status); err != nil {
+			return nil, err
+		}
+
+		t.Data = append(t.Data, status)
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func (t *MockTransport) name() string {
+	if t.Name == "" {
+		return "mock"
+	}
+
+	return t.Name
+}
+
+// wait returns how long to wait before sending the i'th Status.
+func (t *MockTransport) wait(i int) time.Duration {
+	switch {
+	case t.Speed > 0 && i == 0:
+		return 0
+	case t.Speed > 0:
+		return time.Duration(float64(t.Data[i].Time.Sub(t.Data[i-1].Time)) / t.Speed)
+	case t.Interval <= 0:
+		return time.Second
+	default:
+		return t.Interval
+	}
+}
+
+// Login always succeeds.
+func (t *MockTransport) Login(_ context.Context) error {
+	return nil
+}
+
+// UserData returns an account with the one grill.
+func (t *MockTransport) UserData(_ context.Context) ([]byte, error) {
+	return json.Marshal(getUserDataResponse{
+		Things: []thing{{Name: t.name(), FriendlyName: t.name()}},
+	})
+}
+
+// MQTTClient returns a client that replays the Data. It never expires.
+func (t *MockTransport) MQTTClient(_ context.Context, handlers func(*mqtt.ClientOptions)) (mqtt.Client, time.Time, error) {
+	opts := mqtt.NewClientOptions()
+	handlers(opts)
+
+	return &mockClient{transport: t, onConnect: opts.OnConnect}, time.Time{}, nil
+}
+
+// mockClient is the mqtt.Client of a MockTransport. Each subscribed update
+// topic runs one goroutine replaying the data until it is unsubscribed or the
+// client is disconnected. Subscribing to the topic again only replaces the
+// handler, the replay carries on where it was.
+type mockClient struct {
+	transport *MockTransport
+	onConnect mqtt.OnConnectHandler
+
+	mutex     sync.Mutex
+	connected bool
+	replays   map[string]*mockReplay
+}
+
+// mockReplay is the replay of one topic.
+type mockReplay struct {
+	stop    chan struct{}       // closed to stop the replay
+	handler mqtt.MessageHandler // guarded by the client mutex
+}
+
+func (c *mockClient) IsConnected() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.connected
+}
+
+func (c *mockClient) IsConnectionOpen() bool {
+	return c.IsConnected()
+}
+
+func (c *mockClient) Connect() mqtt.Token {
+	c.mutex.Lock()
+	c.connected = true
+	c.mutex.Unlock()
+
+	if c.onConnect != nil {
+		c.onConnect(c)
+	}
+
+	return mockToken{}
+}
+
+func (c *mockClient) Disconnect(_ uint) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.connected = false
+
+	for topic, r := range c.replays {
+		close(r.stop)
+		delete(c.replays, topic)
+	}
+}
+
+func (c *mockClient) Publish(_ string, _ byte, _ bool, _ interface{}) mqtt.Token {
+	return mockToken{}
+}
+
+func (c *mockClient) Subscribe(topic string, _ byte, callback mqtt.MessageHandler) mqtt.Token {
+	if !strings.HasPrefix(topic, "prod/thing/update/") {
+		return mockToken{}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if r, ok := c.replays[topic]; ok {
+		r.handler = callback
+		return mockToken{}
+	}
+
+	if c.replays == nil {
+		c.replays = make(map[string]*mockReplay)
+	}
+
+	r := &mockReplay{stop: make(chan struct{}), handler: callback}
+	c.replays[topic] = r
+
+	go c.replay(topic, r)
+
+	return mockToken{}
+}
+
+func (c *mockClient) SubscribeMultiple(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token {
+	for topic, qos := range filters {
+		c.Subscribe(topic, qos, callback)
+	}
+
+	return mockToken{}
+}
+
+func (c *mockClient) Unsubscribe(topics ...string) mqtt.Token {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, topic := range topics {
+		if r, ok := c.replays[topic]; ok {
+			close(r.stop)
+			delete(c.replays, topic)
+		}
+	}
+
+	return mockToken{}
+}
+
+func (c *mockClient) AddRoute(_ string, _ mqtt.MessageHandler) {}
+
+// OptionsReader returns an empty reader, the mock has no options.
+func (c *mockClient) OptionsReader() mqtt.ClientOptionsReader {
+	return mqtt.ClientOptionsReader{}
+}
+
+func (c *mockClient) replay(topic string, r *mockReplay) {
+	for i, s := range c.transport.Data {
+		select {
+		case <-r.stop:
+			return
+		case <-time.After(c.transport.wait(i)):
+		}
+
+		c.mutex.Lock()
+		handler := r.handler
+		c.mutex.Unlock()
+
+		handler(c, mockMessage{topic: topic, payload: mockPayload(s)})
+	}
+}
+
+// mockPayload returns s in the format of a prod/thing/update message.
+func mockPayload(s Status) []byte {
+	flag := func(b bool) int {
+		if b {
+			return 1
+		}
+
+		return 0
+	}
+
+	unix := func(t time.Time) int64 {
+		if t.IsZero() {
+			return 0
+		}
+
+		return t.Unix()
+	}
+
+	b, _ := json.Marshal(prodThingUpdate{
+		Settings: settings{FirmwareVersion: s.Firmware},
+		Status: status{
+			Ambient:           s.Ambient,
+			Connected:         s.Connected,
+			CookTimerComplete: flag(s.TimerComplete),
+			CookTimerEnd:      unix(s.TimerEnd),
+			CookTimerStart:    unix(s.TimerStart),
+			CurrentCycle:      s.CurrentCycle,
+			CurrentStep:       s.CurrentStep,
+			Errors:            s.Errors,
+			Grill:             s.Grill,
+			InCustom:          flag(s.InCustomCook),
+			KeepWarm:          flag(s.KeepWarm),
+			PelletLevel:       s.PelletLevel,
+			Probe:             s.Probe,
+			ProbeAlarmFired:   flag(s.ProbeAlarmFired),
+			ProbeConnected:    flag(s.ProbeConnected),
+			ProbeSet:          s.ProbeSet,
+			RealTime:          s.RealTime,
+			ServerStatus:      flag(s.ServerOnline),
+			Set:               s.GrillSet,
+			Smoke:             s.Smoke,
+			SystemStatus:      int(s.SystemStatus),
+			Time:              unix(s.Time),
+			Units:             int(s.Units),
+		},
+	})
+
+	return b
+}
+
+// mockToken is an mqtt.Token that has already completed.
+type mockToken struct{}
+
+var closedChannel = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+
+	return ch
+}()
+
+func (mockToken) Wait() bool                       { return true }
+func (mockToken) WaitTimeout(_ time.Duration) bool { return true }
+func (mockToken) Done() <-chan struct{}            { return closedChannel }
+func (mockToken) Error() error                     { return nil }
+
+// mockMessage is an mqtt.Message delivered by the mockClient.
+type mockMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m mockMessage) Duplicate() bool   { return false }
+func (m mockMessage) Qos() byte         { return 1 }
+func (m mockMessage) Retained() bool    { return false }
+func (m mockMessage) Topic() string     { return m.topic }
+func (m mockMessage) MessageID() uint16 { return 0 }
+func (m mockMessage) Payload() []byte   { return m.payload }
+func (m mockMessage) Ack()              {}
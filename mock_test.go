@@ -0,0 +1,114 @@
+package wifire
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMockTransport(t *testing.T) {
+	log := `{"time":"2024-07-04T12:00:00Z","grill":200,"grill_set":225,"units":1}
+{"time":"2024-07-04T12:01:00Z","grill":210,"grill_set":225,"units":1}
+{"time":"2024-07-04T12:02:00Z","grill":220,"grill_set":225,"units":1}
+`
+
+	mock, err := NewMockTransport(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.Interval = time.Millisecond
+
+	w, err := New(UseTransport(mock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := w.UserData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Things) != 1 || data.Things[0].Name != "mock" {
+		t.Fatalf("things %+v, want the one mock grill", data.Things)
+	}
+
+	g := w.NewGrill(data.Things[0].Name)
+	if err := g.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer g.Disconnect()
+
+	ch := make(chan Status, len(mock.Data))
+	if err := g.SubscribeStatus(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []int{200, 210, 220} {
+		select {
+		case s := <-ch:
+			if s.Grill != want || !s.Time.Equal(mock.Data[i].Time) {
+				t.Errorf("%d: grill %d at %s, want %d at %s", i, s.Grill, s.Time, want, mock.Data[i].Time)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%d: no status", i)
+		}
+	}
+
+	if _, err := NewMockTransport(strings.NewReader("{\n")); err == nil {
+		t.Error("malformed log accepted")
+	}
+}
+
+func TestMockResubscribeContinues(t *testing.T) {
+	mock := &MockTransport{Interval: 10 * time.Millisecond}
+	for _, grill := range []int{200, 210, 220} {
+		mock.Data = append(mock.Data, Status{Grill: grill, Units: Fahrenheit})
+	}
+
+	w, err := New(UseTransport(mock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := w.NewGrill("mock")
+	if err := g.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer g.Disconnect()
+
+	ch := make(chan Status, len(mock.Data))
+	if err := g.SubscribeStatus(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := <-ch; s.Grill != 200 {
+		t.Fatalf("first grill %d, want 200", s.Grill)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Status subscribes to the update topic again, which must not restart the
+	// replay.
+	s, err := g.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Grill != 210 {
+		t.Errorf("Status grill %d, want 210", s.Grill)
+	}
+
+	for _, want := range []int{210, 220} {
+		select {
+		case s := <-ch:
+			if s.Grill != want {
+				t.Errorf("grill %d, want %d", s.Grill, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no status with grill %d", want)
+		}
+	}
+}
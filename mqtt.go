@@ -1,31 +1,66 @@
 package wifire
 
 import (
+	"context"
 	"encoding/json"
-	"net/http"
+	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
+// MQTTOptions is an option setting function for New(). The function f is
+// called to customize the MQTT client options (e.g. clean session or
+// keepalive) before connecting. The OnConnect, OnConnectionLost, and
+// OnReconnecting handlers are always replaced by the Grill since it restores
+// the subscriptions after a reconnect, use Grill.OnConnectionChange instead.
+func MQTTOptions(f func(*mqtt.ClientOptions)) func(*WiFire) {
+	return func(w *WiFire) {
+		w.config.mqttOptions = f
+	}
+}
+
+// QoS is an option setting function for New(). It sets the MQTT quality of
+// service for subscriptions, the default is 1 (at least once).
+func QoS(qos byte) func(*WiFire) {
+	return func(w *WiFire) {
+		w.config.qos = qos
+	}
+}
+
+// MQTTKeepAlive is an option setting function for New(). It sets how often
+// the MQTT client pings the broker when idle. The default is the paho default
+// of 30 seconds.
+func MQTTKeepAlive(d time.Duration) func(*WiFire) {
+	return func(w *WiFire) {
+		w.config.mqttKeepAlive = d
+	}
+}
+
+// MQTTPingTimeout is an option setting function for New(). It sets how long
+// the MQTT client waits for a ping response before treating the connection
+// as lost. The default is the paho default of 10 seconds.
+func MQTTPingTimeout(d time.Duration) func(*WiFire) {
+	return func(w *WiFire) {
+		w.config.mqttPingTimeout = d
+	}
+}
+
 type getMQTTResponse struct {
 	ExpirationSeconds int    `json:"expirationSeconds"`
 	ExpiresAt         int    `json:"expiresAt"`
 	SignedURL         string `json:"signedUrl"`
 }
 
-func (w WiFire) getMQTT() (mqtt.Client, error) {
-	req, err := http.NewRequest("POST", w.config.baseURL+"/prod/mqtt-connections", http.NoBody)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("authorization", w.token)
-
-	c := http.Client{}
+// mqttRefreshSkew is how long before the signed broker URL expires that the
+// Grill reconnects with a new one.
+const mqttRefreshSkew = 5 * time.Minute
 
-	r, err := c.Do(req)
+// mqttOptions requests a signed MQTT broker URL and returns the client options
+// for connecting to it and when the URL expires.
+func (w *WiFire) mqttOptions(ctx context.Context) (*mqtt.ClientOptions, time.Time, error) {
+	r, err := w.api(ctx, "POST", "/prod/mqtt-connections")
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
 	}
 
 	defer r.Body.Close()
@@ -33,16 +68,44 @@ func (w WiFire) getMQTT() (mqtt.Client, error) {
 	var data getMQTTResponse
 
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		return nil, err
+		return nil, time.Time{}, err
+	}
+
+	var expires time.Time
+
+	switch {
+	case data.ExpirationSeconds > 0:
+		expires = time.Now().Add(time.Duration(data.ExpirationSeconds) * time.Second)
+	case data.ExpiresAt > 0:
+		expires = time.Unix(int64(data.ExpiresAt), 0)
 	}
 
 	opts := mqtt.NewClientOptions()
 	opts.AddBroker(data.SignedURL)
-	opts.OnConnect = connect
-	opts.OnConnectionLost = connectionLost
-	opts.OnReconnecting = reconnecting
 
-	return mqtt.NewClient(opts), nil
+	if w.config.mqttKeepAlive > 0 {
+		opts.SetKeepAlive(w.config.mqttKeepAlive)
+	}
+
+	if w.config.mqttPingTimeout > 0 {
+		opts.SetPingTimeout(w.config.mqttPingTimeout)
+	}
+
+	if w.config.mqttOptions != nil {
+		w.config.mqttOptions(opts)
+	}
+
+	return opts, expires, nil
+}
+
+// wait waits for the MQTT token to complete or ctx to be canceled.
+func wait(ctx context.Context, t mqtt.Token) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.Done():
+		return t.Error()
+	}
 }
 
 func connect(_ mqtt.Client) {
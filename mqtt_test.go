@@ -0,0 +1,44 @@
+package wifire
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMQTTKeepAlive(t *testing.T) {
+	api := newFakeAPI(t)
+
+	tests := []struct {
+		opts      []func(*WiFire)
+		keepAlive int64 // seconds
+		ping      time.Duration
+	}{
+		{nil, 30, 10 * time.Second}, // the paho defaults
+		{[]func(*WiFire){MQTTKeepAlive(45 * time.Second), MQTTPingTimeout(20 * time.Second)}, 45, 20 * time.Second},
+	}
+
+	for _, tt := range tests {
+		w, err := New(append(api.options(), tt.opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		opts, expires, err := w.mqttOptions(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if opts.KeepAlive != tt.keepAlive || opts.PingTimeout != tt.ping {
+			t.Errorf("keepalive %ds ping timeout %s, want %ds and %s", opts.KeepAlive, opts.PingTimeout, tt.keepAlive, tt.ping)
+		}
+
+		if len(opts.Servers) != 1 || opts.Servers[0].Host != "broker.example" {
+			t.Errorf("brokers %v", opts.Servers)
+		}
+
+		if d := time.Until(expires); d < 59*time.Minute || d > time.Hour {
+			t.Errorf("expires in %s, want an hour", d)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package wifire
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy is an option setting function for New(). It sets the maximum
+// number of attempts for a REST call and the base delay between them. The
+// delay doubles after each attempt and is jittered. Requests are retried on
+// network errors and 429, 500, 502, and 503 responses. The default is three
+// attempts with a 500ms base delay, use one attempt to disable retries.
+func RetryPolicy(maxAttempts int, base time.Duration) func(*WiFire) {
+	return func(w *WiFire) {
+		w.config.maxAttempts = maxAttempts
+		w.config.retryBase = base
+	}
+}
+
+// doWithRetry sends the request returned by newRequest, retrying on transient
+// failures. A new request is created for each attempt so the body can be
+// resent.
+func (w *WiFire) doWithRetry(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := w.config.httpClient.Do(req)
+		if attempt >= w.config.maxAttempts || !retryable(ctx, resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if Logger != nil {
+			Logger(LogWarn, "wifire", "retrying "+req.URL.Path)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(w.config.backoff(attempt)):
+		}
+	}
+}
+
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil && !errors.Is(err, context.Canceled)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable:
+		return true
+	}
+
+	return false
+}
+
+// backoff returns the jittered delay after the given attempt, between half and
+// all of base * 2^(attempt-1).
+func (c *config) backoff(attempt int) time.Duration {
+	d := c.retryBase << (attempt - 1)
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1)) //nolint:gosec // jitter does not need a secure source
+}
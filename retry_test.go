@@ -0,0 +1,63 @@
+package wifire
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryTransientFailures(t *testing.T) {
+	api := newFakeAPI(t)
+
+	w, err := New(api.options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api.users = []int{http.StatusServiceUnavailable, http.StatusBadGateway}
+
+	if _, err := w.UserData(); err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	for _, r := range api.requests() {
+		if r == "GET /prod/users/self" {
+			n++
+		}
+	}
+
+	if n != 3 {
+		t.Errorf("%d attempts, want 3", n)
+	}
+}
+
+func TestRetryCanceledBetweenAttempts(t *testing.T) {
+	api := newFakeAPI(t)
+
+	w, err := New(append(api.options(), RetryPolicy(3, time.Hour))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api.users = []int{http.StatusServiceUnavailable}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	if _, err := w.UserDataContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	if time.Since(start) > 10*time.Second {
+		t.Error("backoff not interrupted")
+	}
+
+	if got := api.requests(); got[len(got)-1] != "GET /prod/users/self" || len(got) != 2 {
+		t.Errorf("got %q, want one attempt", got)
+	}
+}
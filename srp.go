@@ -0,0 +1,294 @@
+package wifire
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// AuthFlow is the Cognito authentication flow used to login.
+type AuthFlow int
+
+// The supported AuthFlows.
+const (
+	// PasswordAuth sends the password to Cognito (USER_PASSWORD_AUTH). This
+	// is the default.
+	PasswordAuth AuthFlow = iota
+	// SRPAuth uses the Secure Remote Password protocol (USER_SRP_AUTH) so the
+	// password is never sent to Cognito. This requires the user pool ID, see
+	// UserPool.
+	SRPAuth
+)
+
+// Authentication is an option setting function for New(). It sets the
+// AuthFlow used to login, the default is PasswordAuth.
+func Authentication(f AuthFlow) func(*WiFire) {
+	return func(w *WiFire) {
+		w.config.authFlow = f
+	}
+}
+
+// UserPool is an option setting function for New(). It sets the Cognito user
+// pool ID (e.g. "us-west-2_abc123"), this is only needed for SRPAuth.
+func UserPool(id string) func(*WiFire) {
+	return func(w *WiFire) {
+		w.config.userPoolID = id
+	}
+}
+
+// srpN is the 3072-bit group from RFC 5054 used by Cognito.
+const srpN = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+	"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+	"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+	"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+	"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+	"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+	"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+	"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+	"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+	"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+	"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF"
+
+var (
+	srpBigN = mustHexToBig(srpN)
+	srpG    = big.NewInt(2)
+	srpK    = mustHexToBig(hexHash("00" + srpN + "02"))
+
+	srpCognito = srpGroup{N: srpBigN, g: srpG, k: srpK}
+)
+
+// srpGroup is an SRP group, the prime N and generator g, with the multiplier
+// k derived from them.
+type srpGroup struct {
+	N, g, k *big.Int
+}
+
+type respondToAuthChallengeBody struct {
+	ChallengeName      string            `json:"ChallengeName"`
+	ChallengeResponses map[string]string `json:"ChallengeResponses"`
+	ClientID           string            `json:"ClientId"`
+}
+
+// loginSRP obtains a new token using the SRP flow.
+func (w *WiFire) loginSRP(ctx context.Context) error {
+	_, poolName, ok := strings.Cut(w.config.userPoolID, "_")
+	if !ok {
+		return fmt.Errorf("invalid user pool ID %q", w.config.userPoolID)
+	}
+
+	s, err := newSRP()
+	if err != nil {
+		return err
+	}
+
+	var challenge requestTokenResponse
+
+	err = w.cognito(ctx, "InitiateAuth", requestTokenBody{
+		AuthFlow: "USER_SRP_AUTH",
+		AuthParameters: map[string]string{
+			"USERNAME": w.config.username,
+			"SRP_A":    s.publicA.Text(16),
+		},
+		ClientID: w.config.clientID,
+	}, &challenge)
+	if err != nil {
+		return err
+	}
+
+	if challenge.ChallengeName != "PASSWORD_VERIFIER" {
+		return fmt.Errorf("unexpected challenge %q", challenge.ChallengeName)
+	}
+
+	params := challenge.ChallengeParameters
+	userID := params["USER_ID_FOR_SRP"]
+	timestamp := time.Now().UTC().Format("Mon Jan 2 15:04:05 UTC 2006")
+
+	signature, err := s.passwordClaim(poolName, userID, w.config.password,
+		params["SALT"], params["SRP_B"], params["SECRET_BLOCK"], timestamp)
+	if err != nil {
+		return err
+	}
+
+	t0 := time.Now()
+
+	var auth requestTokenResponse
+
+	err = w.cognito(ctx, "RespondToAuthChallenge", respondToAuthChallengeBody{
+		ChallengeName: "PASSWORD_VERIFIER",
+		ChallengeResponses: map[string]string{
+			"USERNAME":                    userID,
+			"TIMESTAMP":                   timestamp,
+			"PASSWORD_CLAIM_SECRET_BLOCK": params["SECRET_BLOCK"],
+			"PASSWORD_CLAIM_SIGNATURE":    signature,
+		},
+		ClientID: w.config.clientID,
+	}, &auth)
+	if err != nil {
+		return err
+	}
+
+	w.setToken(t0, auth.AuthenticationResult)
+
+	return nil
+}
+
+// srp is the client side of a single SRP exchange.
+type srp struct {
+	privateA *big.Int // a
+	publicA  *big.Int // A = g^a % N
+}
+
+func newSRP() (*srp, error) {
+	b := make([]byte, 128)
+
+	for {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+
+		a := new(big.Int).SetBytes(b)
+		a.Mod(a, srpBigN)
+
+		A := new(big.Int).Exp(srpG, a, srpBigN)
+		if A.Sign() != 0 {
+			return &srp{privateA: a, publicA: A}, nil
+		}
+	}
+}
+
+// passwordClaim returns the base64 encoded PASSWORD_CLAIM_SIGNATURE for the
+// PASSWORD_VERIFIER challenge.
+func (s *srp) passwordClaim(poolName, userID, password, salt, srpB, secretBlock, timestamp string) (string, error) {
+	key, err := s.authenticationKey(poolName, userID, password, salt, srpB)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := base64.StdEncoding.DecodeString(secretBlock)
+	if err != nil {
+		return "", fmt.Errorf("invalid secret block: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(poolName))
+	mac.Write([]byte(userID))
+	mac.Write(block)
+	mac.Write([]byte(timestamp))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// authenticationKey computes the shared session key S and derives the 16 byte
+// authentication key from it.
+func (s *srp) authenticationKey(poolName, userID, password, salt, srpB string) ([]byte, error) {
+	B, ok := new(big.Int).SetString(srpB, 16)
+	if !ok || new(big.Int).Mod(B, srpBigN).Sign() == 0 {
+		return nil, errors.New("invalid SRP_B")
+	}
+
+	u := srpU(s.publicA, B)
+	if u.Sign() == 0 {
+		return nil, errors.New("invalid SRP_B, u is zero")
+	}
+
+	// The salt is padded as a number, like the Cognito SDKs, so leading
+	// zeros in the SALT string are dropped.
+	n, ok := new(big.Int).SetString(salt, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid salt %q", salt)
+	}
+
+	x := srpX(n, poolName, userID, password)
+	S := srpCognito.S(B, x, u, s.privateA)
+
+	// Cognito calls the key the "Caldera Derived Key".
+	return hkdf(mustHexDecode(padHex(S)), mustHexDecode(padHex(u)), "Caldera Derived Key")[:16], nil
+}
+
+// srpU returns the scrambling parameter u = H(A | B).
+func srpU(A, B *big.Int) *big.Int {
+	return hexToBig(hexHash(padHex(A) + padHex(B)))
+}
+
+// srpX returns the private key x = H(salt | H(poolName | userID ":" password)).
+func srpX(salt *big.Int, poolName, userID, password string) *big.Int {
+	userHash := sha256.Sum256([]byte(poolName + userID + ":" + password))
+	return hexToBig(hexHash(padHex(salt) + hex.EncodeToString(userHash[:])))
+}
+
+// S returns the session key S = (B - k * g^x) ^ (a + u * x) % N.
+func (grp srpGroup) S(B, x, u, a *big.Int) *big.Int {
+	base := new(big.Int).Exp(grp.g, x, grp.N)
+	base.Mul(base, grp.k)
+	base.Sub(B, base)
+	base.Mod(base, grp.N)
+
+	exp := new(big.Int).Mul(u, x)
+	exp.Add(exp, a)
+
+	return new(big.Int).Exp(base, exp, grp.N)
+}
+
+// hkdf returns the first 32 bytes of the HKDF-SHA256 (RFC 5869) output.
+func hkdf(ikm, salt []byte, info string) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(ikm)
+
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte(info + "\x01"))
+
+	return expand.Sum(nil)
+}
+
+// padHex returns the hex encoding of n padded so it has an even length and
+// is not interpreted as negative.
+func padHex(n *big.Int) string {
+	s := n.Text(16)
+
+	switch {
+	case len(s)%2 == 1:
+		return "0" + s
+	case strings.ContainsAny(s[:1], "89abcdef"):
+		return "00" + s
+	default:
+		return s
+	}
+}
+
+// hexHash returns the hex encoded SHA-256 of the hex encoded data.
+func hexHash(s string) string {
+	sum := sha256.Sum256(mustHexDecode(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hexToBig(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 16)
+	return n
+}
+
+func mustHexToBig(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("invalid hex " + s)
+	}
+
+	return n
+}
+
+func mustHexDecode(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
@@ -0,0 +1,325 @@
+package wifire
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// The SRP vectors were generated with fixed a and b values following the
+// AuthenticationHelper in amazon-cognito-identity-js. They were also checked
+// against the server side S = (A * v^u)^b % N. The published vectors of
+// RFC 5054 and RFC 5869 are checked by TestSRPRFC5054 and TestHKDF.
+const (
+	srpTestPool      = "abc123"
+	srpTestUserID    = "user-id-1234"
+	srpTestPassword  = "hunter2"
+	srpTestBlock     = "c2VjcmV0IGJsb2Nr"
+	srpTestTimestamp = "Tue Sep 5 13:02:03 UTC 2023"
+)
+
+var srpVectors = []struct {
+	name      string
+	a         string
+	salt      string
+	A         string
+	B         string
+	u         string
+	x         string
+	S         string
+	key       string
+	signature string
+}{
+	{
+		name: "salt with the high bit set",
+		a:    "1f2e3d4c5b6a79881726354453627180",
+		salt: "8a2b3c4d5e6f708192a3b4c5d6e7f801",
+		A: "332a466bc70944a2cb1b142ba96e5c29cff4f5057129d983deb82954eff6f740" +
+			"4562e28e06eb0a5a319732e7067fd131d2a1178daf3bc5623cdc5e7841a9065e" +
+			"e76ec28c013cbc7c77bf7ad6ee45c27fd61fee9a63b3c94afb904486e976d029" +
+			"193788d46b7fb51dbacc4c8274fb125c1d48b24cbe6646dec65f32c7b0d891e7" +
+			"c3109f099ac6555ee92eeca06c4e97aa640bf958517c3e2ab7ebf35f081686d0" +
+			"8b935efa195cef363d6d2f198cd2fd45406743a5e198922575cfe15993b5bfcf" +
+			"f6906374050529fc390a0253b077a74a238825f852549d4769ab5e7f9eeeadf5" +
+			"10bf153dda2f028c68195ca276395abbc17f5ae6cd55127d73996677c2c4dce1" +
+			"4438437cc5c47c1d641243e17a87ee4f4a1532195bbd76df416ed1d57c314276" +
+			"114c4b114f4f2d39e454e988542e9dd4c31f552691151996585bcbb5990c7e8b" +
+			"f31b9c0fec84588b1af05c13cdb5b6574b12050c4ef3207ddd5b1ec840fb5403" +
+			"bdedb7c9c84e4fe902abc676e9dfe9b78a6ebe85a452c4ea7c61724996249f3",
+		B: "e99f952aba249876355aa8eb3aa37fa0e676942987956609db0310b40b1c60f9" +
+			"0716e30396cfabcc7e3dd9a63d86a8546d198feaee454d7353bb42200ab3903d" +
+			"19ca63391a3a8ad1b668d8027c23b3511987bee14e7b1edbbae7151e30cec338" +
+			"b4e148cac0e167a901a6af09ef2a04ef052e381fc9e2344bb7f8b98f648e9040" +
+			"f9edeb4db497c4c7b7e8d2f229846abb7111645eda570b43131f567f30da765a" +
+			"5108b8e5d680527866dc3d37b17d27f972b367c02f3f67067cff58445ba0a4c4" +
+			"16b97478c979b33eefff081902244431581616f729aca5e497acc6da5616804b" +
+			"171a4c1416353874d2ffb6a0ebad04b8bf2c139eccf36e932d570e0c222774b3" +
+			"a85701f14cd11c972d0d7ad1cbc1764dd23c33fff904d4e3a81228575777fab3" +
+			"11113eed5e8bfc57655a9c761e9a5910d0395fa5568f9b1ae3843301f84cbecf" +
+			"dee7b44570cd37e67b98c2a5a16520c5915de1d3d43bb43b16c630b4ecc9e985" +
+			"9aa2bf0b25efc6779971126f158a9e217cab4dd5ebbe9dfbb4641b8fa99b68d7",
+		u: "8fefc9df017fbda9cc095084f225a35daf49c0e507e6d9659ba67640414b2033",
+		x: "ee5f7558f56f74428b4b1203bf1ed57e4e6fab80e3a92dc588aa7eb4a9d592b",
+		S: "4b9b7388ae30a2a082d58b52a8c5e7c19daedf3c9c665da622ea4bb9ffcdb6f9" +
+			"1a46f77f49bbc38ba957d8ebb639d6bb03a7eb47525787d4831a6cfd73bf3097" +
+			"7c91c0d2091f20d9287d8e7742aadc439c679ad19e2a0ab0ab00a9176a6c3d2f" +
+			"8fdc1bd1426cd7d206b33b6ddd521f3c890f687db07c6887cdcb7b9a40617b52" +
+			"0285a57877d1ef64cf23d32010cb6d54d33d517555f3bc9d87c16fa40d6d16cf" +
+			"bf233ebc3c16aefe1b393f4d848123321b8011d2550f307b33860b7e51a93050" +
+			"e9c02fdd70dca0b82e243df035849a1be35085e6849877acf571d5debd1d8248" +
+			"190dec069216b08db36b50e6c2cc085b645b0a36398b80e7c97a507a1cbac8de" +
+			"cac6f40dc33fdacc4faa8cc7d0290b7790a2c18bb0f185952373f392d27418a9" +
+			"6cd6d3c8c24f38d6ce9667c35b69da44f4fb501f2b4b324e1053ab332874de34" +
+			"e3f6281d6ad95d37877203ebf698f5082d29a4ca5fe1c216bdd444430aab3128" +
+			"ab82c00776733be207ec87080d76fc4ae15078db267d140f3ab9ebb8281b2f0b",
+		key:       "bad367896689ed3b0ad058b3895a0745",
+		signature: "dnYvlTvNmzFWCPTLo3VkvtfJGV57cXNGwDCGCU7SozQ=",
+	},
+	{
+		name: "salt with a leading zero nibble",
+		a:    "deadbeefcafebabe0123456789abcdef",
+		salt: "0f1e2d3c4b5a69788796a5b4c3d2e1f0",
+		A: "4c381e933a640bce216557d466944bfb7c4d29946859a3f17031fabfe128d2b9" +
+			"71fc7ab3e327961963ec7671ae06562fcf3aa4bca71a5857580b94c5754f651c" +
+			"3b462256e7a5f28b14f415b977d1b0413dc73b609d12d7ce0140ac1f027b8dd0" +
+			"bfe8e74a8e537baf2c2978179d7108d4b2a3025499b08eb1d14e00fb732f9b09" +
+			"8fd24bbd1dbcb9649a23ddd4d6d318dc36fb18b2131a87ebe3cd17ae6a1c36cd" +
+			"7544d3b8248f78adc515c512e4ccba4e058d5ca9312975c7e197e6191a375edd" +
+			"5d9ee91952b1c9f6f1762db24ff0e3cee9ac53e964d26c89dbfd2d4e5930a23d" +
+			"4c767172d2959140bdce2fddb69f2981b8f0c191ccc61dd47ab644a5de2ca92a" +
+			"95b097acdf7883344fd589a49d0e665577860c697b0293d43ea00d0ee9d09565" +
+			"de52143f3726fc65bd9c255a871bb00e96c25bcd4e8755dc9644b93daa2da314" +
+			"66a92de62851e7360dbc54abce95c0469cc2c98ca74b04175f64366432fabe0a" +
+			"7df9fbfc5dfad7375a7eb9f114ecca8fc2f718fe82029036822a81219da5f2e7",
+		B: "3f3b77f8fcc207555cdb146fd026c54cc37898fa92d4dd1da147a0363a727cc3" +
+			"2058c79eff5abdfd66824a8e1fe2ea9d173905bdffa3591c5b7d0f01d8d877c5" +
+			"887a8eaa4fe7b0d437af9e3b3d4c24833313d59991e44ac9cc33c622840a4f5b" +
+			"cb6763395d8c3d654d96d475d0b0dad2e67a1e74221b2a1d1d3c646649b65d2f" +
+			"c71b7514095617db8885bb5d8697cffd291347fd8537c4f7e53f108699c0d701" +
+			"0042b370e4eedb579400ef5aab0b2b513bc1d7da8e4701845334473d16ee6bf3" +
+			"bf7995968da8f36fcb51106877a6a647ac6c43af19257e1aa4710f28034aaa42" +
+			"f0bf648adb1b3be38e0ba0f0d2f9d088c68a293e599e912b8d91f8e8ed253399" +
+			"bcaf6ffe9f00ba2057bb07fd8dd6bc90462bd2501ba7a5e300094fc8dc2b8247" +
+			"cee908bf435b2d18210411921338b4571ca41a5d7f374cfdcb6d4e89fafd49b2" +
+			"b2dbb52684b79da198736a1637a2a4654618e1ec776cc1ee05c16944d0ef385f" +
+			"d761b249671815c497f02d927c9e673b6a89f4d07024cc79a848654dfd258880",
+		u: "4f2c5fd58f2dbd2397fc050dc70635103e23fecabde3df21b5c2120e7ea2dddc",
+		x: "618da6b6f42188facb3a7e76d4610b960db84dbf258b12eb58ee0d0257195cf9",
+		S: "489039452e9bf5a410e7cc0a3f252a1d6063e5cb31d02d84c123f0c6f0500e72" +
+			"d181c64a225039651b45c8bf74dc8b5156685cd6adfb56e4cf0bbd39cfc2694d" +
+			"0888016e3ba6a10fe5da2bd3988547bf8b93a5a1ed46b0a1cf4da1be5e7f7c56" +
+			"ab8ddf5c7e507bdfacbfab9791f9d55f3b5f7d893ae1b128ae333c4dbd37b1dd" +
+			"5ea8e07e0aab9658799f1e333f5b9c32787b043de38280193af78f58be0fe6e3" +
+			"b1d899ec74ac7a53657a9bda1629b864d5b458f8a351c19278e6f3078395e20e" +
+			"2374a4598851008168ce2dc9392e601517fc9cbbcc56b108331901cf5f1e6661" +
+			"6b030b22628d395276d56a15269aea09157cdf82070609515dff00c280a31654" +
+			"4d33cd9d8ff5deec007ffce8b22080308c0a87bed88d978295ac5da47af7742f" +
+			"9ef34c6cd88d5bd31f27bb389aceb31fec1914f1abdde62cd8e73cb38195628a" +
+			"c2864fd7d5e42b7e7c08c9efaa67844903f45a7e9192a70c1a713b6aff07e5d5" +
+			"8ece748f81c2ebf769f6361b7105f8b46027dbcecf33607d9b6c6186d5d392d4",
+		key:       "ef8cb9e32f119461930109e6e8063bc4",
+		signature: "IZTlGleCD0GfIm+Px4e9oXx82DUmQjuAjIzr2nI21/0=",
+	},
+	{
+		name: "salt with a leading zero byte",
+		a:    "123456789abcdeffedcba9876543210",
+		salt: "007e2d3c4b5a69788796a5b4c3d2e1f0",
+		A: "bdf9150525b153af41ad4f88e872c83f3826bd3b0bf697c9c9febab0bfd9970a" +
+			"ad757147eee2988ef2ea2e3c968280a5e9d8b8193f2299962694bc77f3fc49dd" +
+			"6b796632c29c1d832efad194c009276641b6661ecbd090d9693f285ce1635adb" +
+			"4fb05c41aa05d80f7d35196d7b5bbcea20d27493e95d176f6aa01ae00b88e183" +
+			"4266d533c3a7f82396a1efa781270be663b83a2566b4e40dc44397d7822addf0" +
+			"df62bdb79ac8253d9f8d0da023dbf0f02c7f84dd78eb49fb8c6f7e0bc27afe7d" +
+			"4a87869f889c883769c86d83d9e80fcd77bd3a56ad484935fd67b9247032e005" +
+			"d5cd01724d6bb5f5abb7ce02d86433baf56de20cc443132cb4fca57be4967db4" +
+			"d22c76e38f5926fd71721b175363c79d54934b141d8ca8e806e88a20c4eed6bb" +
+			"ab8244e1e222b399012bebe404fa9ab0fa361c812392f292be2cc34f858da1e3" +
+			"22d0694a501dd89a04cd25047f7dfd212abcecbd2356218d29b23f8aa2e7c780" +
+			"888bd8dc3d6638ba9858d767770a146719df44636b6f82d4b57bbce59ec36f29",
+		B: "d521aafbb74a07999b9dd503718a27f0097ab1891844d920b6e96f0a4f98c0e6" +
+			"b1f5dc9213786e12819b4be36621e2915cde73155704d865c358e6d574a7cc39" +
+			"76d52aa0138dc1e42b70bc194ba752a624bcc032bac5a1bbc597a9e824fcd068" +
+			"c7ce1f47cf109459f03f496195520a3a9b30554d0de1c1a3006f0327107f95f0" +
+			"3cf347acff7c722798e5b311eee8d4b8c9edc2b136152d9662079936bc84259b" +
+			"3a541b286828242e1c65614cc7d40e78344f361dee76acffb5d2beccf053697b" +
+			"27d337502a76a7f42466f35cd23c1d773bc6085859c3fe080bd93bbf9de960f8" +
+			"83905671a7e01140bdb808648c7b7f4f1b67b2fb23de87e8659b2bd341eb31c6" +
+			"b3d31b2bb9c10dab9a5c078726367f1ae1d1998e4b724e4946d808789f98f968" +
+			"f2caff9a60b37bee85df30de71241d70728cb42305ab2f64bb4372e9ce141b44" +
+			"3a72da670dedba98dc5b22e31a849b130960cec71f8303bfb6507d67e1401b48" +
+			"27d537a66be78298e7d3db0ae4660a536177eaa6192d41d6678ec924efc56115",
+		u: "26e7fbd57770a51c659539aa0f1cf08589fee7913869877a094e4670b6114fac",
+		x: "a3c90c460daab235fd394344bd610a7bf750bf037ea9d247cc10be8a7062704a",
+		S: "7a3b7e28ffe704061d8a48da4867a37dd0afae1a0c6110e706f4c074ceb6fcb3" +
+			"d174d26d60a0cdf1a34f7dafd2c3dabb4e9b967b98e21f9984e26e71f40cb7d4" +
+			"0ceecb97ba24a473a5d719211cc2810e6d9aba092b2618456fe3e67e263b044b" +
+			"9521df147f1eca3e1fde3011a4443c65667610f6d8711fb5306af2ea469216ca" +
+			"e1aaacc4fc0aaf84e51f684a668aaf29bf80120df7396b5487e28a9b3bf4d4d2" +
+			"0ef77da2a5200c8d136ca5cd7c5d68d7792b577455977ce15482b0142fe40432" +
+			"b6df8115b29e7a6071ba1801c0fbe99fd5108b8df6be06940a58db0b916c6740" +
+			"e4d62a92e66cb950b8e26b2e331b7cd9449cea114c363f50a46c2800a81200f0" +
+			"1229c95ccfc8fc31b64b440735a8dbf8bf7ed6496d321196a51d1f391b3991c4" +
+			"88ddfe80c0758d1270160606d220c5dc52e8764059d6d654f65994d51c480565" +
+			"b6ae49163d799b18825af98fc4fbb6ca5ccb0c7a7143329148a6d6dea5cd7109" +
+			"3c9caabe92218353e744d9eefa75bba81ac9e7a8e1774ce81221b8d9452dd8c3",
+		key:       "5d2caa64f43283003542c2cdcfad5675",
+		signature: "EwA9Kiyt+N7Md1thsPU+i/aTmhsfxTzDAbgGp1fpy/Y=",
+	},
+}
+
+func TestSRPVectors(t *testing.T) {
+	for _, v := range srpVectors {
+		t.Run(v.name, func(t *testing.T) {
+			a := mustHexToBig(v.a)
+			s := srp{privateA: a, publicA: new(big.Int).Exp(srpG, a, srpBigN)}
+
+			if got := s.publicA.Text(16); got != v.A {
+				t.Errorf("A = %s, want %s", got, v.A)
+			}
+
+			B := mustHexToBig(v.B)
+
+			u := srpU(s.publicA, B)
+			if got := u.Text(16); got != v.u {
+				t.Errorf("u = %s, want %s", got, v.u)
+			}
+
+			x := srpX(mustHexToBig(v.salt), srpTestPool, srpTestUserID, srpTestPassword)
+			if got := x.Text(16); got != v.x {
+				t.Errorf("x = %s, want %s", got, v.x)
+			}
+
+			if got := srpCognito.S(B, x, u, a).Text(16); got != v.S {
+				t.Errorf("S = %s, want %s", got, v.S)
+			}
+
+			key, err := s.authenticationKey(srpTestPool, srpTestUserID, srpTestPassword, v.salt, v.B)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := hex.EncodeToString(key); got != v.key {
+				t.Errorf("key = %s, want %s", got, v.key)
+			}
+
+			sig, err := s.passwordClaim(srpTestPool, srpTestUserID, srpTestPassword, v.salt, v.B, srpTestBlock, srpTestTimestamp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sig != v.signature {
+				t.Errorf("signature = %s, want %s", sig, v.signature)
+			}
+		})
+	}
+}
+
+// TestSRPRFC5054 checks A and S against the SRP test vector published in
+// RFC 5054 Appendix B. It uses the 1024-bit group and SHA-1, so k, x, and u
+// are taken from the RFC rather than computed the Cognito way.
+func TestSRPRFC5054(t *testing.T) {
+	hexBig := func(s string) *big.Int { return mustHexToBig(strings.ReplaceAll(s, " ", "")) }
+
+	grp := srpGroup{
+		N: hexBig("EEAF0AB9 ADB38DD6 9C33F80A FA8FC5E8 60726187 75FF3C0B 9EA2314C" +
+			"9C256576 D674DF74 96EA81D3 383B4813 D692C6E0 E0D5D8E2 50B98BE4" +
+			"8E495C1D 6089DAD1 5DC7D7B4 6154D6B6 CE8EF4AD 69B15D49 82559B29" +
+			"7BCF1885 C529F566 660E57EC 68EDBC3C 05726CC0 2FD4CBF4 976EAA9A" +
+			"FD5138FE 8376435B 9FC61D2F C0EB06E3"),
+		g: big.NewInt(2),
+		k: hexBig("7556AA04 5AEF2CDD 07ABAF0F 665C3E81 8913186F"),
+	}
+
+	x := hexBig("94B7555A ABE9127C C58CCF49 93DB6CF8 4D16C124")
+	a := hexBig("60975527 035CF2AD 1989806F 0407210B C81EDC04 E2762A56 AFD529DD DA2D4393")
+	u := hexBig("CE38B959 3487DA98 554ED47D 70A7AE5F 462EF019")
+
+	wantA := hexBig("61D5E490 F6F1B795 47B0704C 436F523D D0E560F0 C64115BB 72557EC4" +
+		"4352E890 3211C046 92272D8B 2D1A5358 A2CF1B6E 0BFCF99F 921530EC" +
+		"8E393561 79EAE45E 42BA92AE ACED8251 71E1E8B9 AF6D9C03 E1327F44" +
+		"BE087EF0 6530E69F 66615261 EEF54073 CA11CF58 58F0EDFD FE15EFEA" +
+		"B349EF5D 76988A36 72FAC47B 0769447B")
+	B := hexBig("BD0C6151 2C692C0C B6D041FA 01BB152D 4916A1E7 7AF46AE1 05393011" +
+		"BAF38964 DC46A067 0DD125B9 5A981652 236F99D9 B681CBF8 7837EC99" +
+		"6C6DA044 53728610 D0C6DDB5 8B318885 D7D82C7F 8DEB75CE 7BD4FBAA" +
+		"37089E6F 9C6059F3 88838E7A 00030B33 1EB76840 910440B1 B27AAEAE" +
+		"EB4012B7 D7665238 A8E3FB00 4B117B58")
+	wantS := hexBig("B0DC82BA BCF30674 AE450C02 87745E79 90A3381F 63B387AA F271A10D" +
+		"233861E3 59B48220 F7C4693C 9AE12B0A 6F67809F 0876E2D0 13800D6C" +
+		"41BB59B6 D5979B5C 00A172B4 A2A5903A 0BDCAF8A 709585EB 2AFAFA8F" +
+		"3499B200 210DCC1F 10EB3394 3CD67FC8 8A2F39A4 BE5BEC4E C0A3212D" +
+		"C346D7E4 74B29EDE 8A469FFE CA686E5A")
+
+	if A := new(big.Int).Exp(grp.g, a, grp.N); A.Cmp(wantA) != 0 {
+		t.Errorf("A = %x, want %x", A, wantA)
+	}
+
+	if S := grp.S(B, x, u, a); S.Cmp(wantS) != 0 {
+		t.Errorf("S = %x, want %x", S, wantS)
+	}
+}
+
+// TestHKDF checks the first block of RFC 5869 Appendix A.1.
+func TestHKDF(t *testing.T) {
+	ikm := bytes.Repeat([]byte{0x0b}, 22)
+	salt := mustHexDecode("000102030405060708090a0b0c")
+	info := string(mustHexDecode("f0f1f2f3f4f5f6f7f8f9"))
+
+	const want = "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf"
+
+	if got := hex.EncodeToString(hkdf(ikm, salt, info)); got != want {
+		t.Errorf("okm = %s, want %s", got, want)
+	}
+}
+
+func TestSRPK(t *testing.T) {
+	const want = "538282c4354742d7cbbde2359fcf67f9f5b3a6b08791e5011b43b8a5b66d9ee6"
+
+	if got := srpK.Text(16); got != want {
+		t.Errorf("k = %s, want %s", got, want)
+	}
+}
+
+func TestPadHex(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0x0, "00"},
+		{0x1, "01"},
+		{0x7f, "7f"},
+		{0x80, "0080"},
+		{0xabc, "0abc"},
+		{0x1234, "1234"},
+		{0xf00d, "00f00d"},
+	}
+
+	for _, tt := range tests {
+		if got := padHex(big.NewInt(tt.n)); got != tt.want {
+			t.Errorf("padHex(%#x) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSRPSaltLeadingZeros(t *testing.T) {
+	// Leading zeros in the SALT string are not part of the salt.
+	want := srpX(mustHexToBig("f1e"), srpTestPool, srpTestUserID, srpTestPassword)
+
+	for _, salt := range []string{"0f1e", "000f1e"} {
+		if got := srpX(mustHexToBig(salt), srpTestPool, srpTestUserID, srpTestPassword); got.Cmp(want) != 0 {
+			t.Errorf("salt %s: x = %s, want %s", salt, got.Text(16), want.Text(16))
+		}
+	}
+}
+
+func TestSRPInvalidB(t *testing.T) {
+	s := srp{privateA: big.NewInt(3), publicA: big.NewInt(8)}
+
+	for _, b := range []string{"", "xyz", "0", srpN} {
+		if _, err := s.authenticationKey(srpTestPool, srpTestUserID, srpTestPassword, "0f1e", b); err == nil {
+			t.Errorf("SRP_B %.8q: expected an error", b)
+		}
+	}
+}
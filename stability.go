@@ -0,0 +1,67 @@
+package wifire
+
+import "math"
+
+// GrillStability measures how well the grill holds its set point over the
+// most recent run of data with an unchanged GrillSet, starting once the grill
+// first reaches the set point so the preheat is not counted. stdDev is the
+// standard deviation of Grill from GrillSet and overshoots is the number of
+// times the grill temperature crosses the set point. Both are zero if the
+// grill never reached the set point.
+func GrillStability(data []Status) (stdDev float64, overshoots int) {
+	last := len(data) - 1
+	for last >= 0 && data[last].Error != nil {
+		last--
+	}
+
+	if last < 0 {
+		return 0, 0
+	}
+
+	set := data[last].GrillSet
+
+	first := last
+	for first > 0 && (data[first-1].Error != nil || data[first-1].GrillSet == set) {
+		first--
+	}
+
+	var (
+		n       int
+		sum     float64
+		sign    int
+		reached bool
+	)
+
+	for _, s := range data[first : last+1] {
+		if s.Error != nil {
+			continue
+		}
+
+		e := s.Grill - set
+
+		if !reached {
+			if e < 0 {
+				continue
+			}
+
+			reached = true
+		}
+
+		n++
+		sum += float64(e * e)
+
+		if (e > 0 && sign < 0) || (e < 0 && sign > 0) {
+			overshoots++
+		}
+
+		if e != 0 {
+			sign = e
+		}
+	}
+
+	if n == 0 {
+		return 0, 0
+	}
+
+	return math.Sqrt(sum / float64(n)), overshoots
+}
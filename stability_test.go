@@ -0,0 +1,29 @@
+package wifire
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestGrillStability(t *testing.T) {
+	data := append(grillSeries(180, 180, 185, 175), grillSeries(225, 200, 225, 230, 220, 230, 220)...)
+	data = append(data, Status{Error: errors.New("bad payload")}) // ignored
+
+	// From the first 225: errors of 0, 5, -5, 5, -5 and three crossings.
+	stdDev, overshoots := GrillStability(data)
+
+	if math.Abs(stdDev-math.Sqrt(20)) > 1e-9 || overshoots != 3 {
+		t.Errorf("got %.3f and %d, want %.3f and 3", stdDev, overshoots, math.Sqrt(20))
+	}
+}
+
+func TestGrillStabilityNeverReached(t *testing.T) {
+	if stdDev, overshoots := GrillStability(grillSeries(225, 150, 180, 200)); stdDev != 0 || overshoots != 0 {
+		t.Errorf("got %.3f and %d, want zeros", stdDev, overshoots)
+	}
+
+	if stdDev, overshoots := GrillStability(nil); stdDev != 0 || overshoots != 0 {
+		t.Errorf("got %.3f and %d for no data", stdDev, overshoots)
+	}
+}
@@ -0,0 +1,127 @@
+package wifire
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TokenStore persists the login tokens so a new process can skip the
+// password login.
+type TokenStore interface {
+	Load() (idToken, refreshToken string, err error)
+	Save(idToken, refreshToken string, expires time.Time) error
+}
+
+// TokenStorage is an option setting function for New(). It sets the
+// TokenStore used to reload the tokens from a previous login and to save
+// them after each new login.
+func TokenStorage(s TokenStore) func(*WiFire) {
+	return func(w *WiFire) {
+		w.config.tokenStore = s
+	}
+}
+
+// IDToken is an option setting function for New(). It seeds the login with
+// an ID token and refresh token obtained elsewhere, such as from the Traeger
+// App or a previous run, so the username and password are not needed. An
+// expired or malformed ID token is refreshed immediately. The seeded tokens
+// take precedence over any TokenStore.
+func IDToken(idToken, refreshToken string) func(*WiFire) {
+	return func(w *WiFire) {
+		w.refreshToken = refreshToken
+
+		if expires, err := tokenExpiry(idToken); err == nil {
+			w.token = idToken
+			w.tokenExpires = expires
+		}
+	}
+}
+
+// FileTokenStore is a TokenStore that keeps the tokens in a JSON file
+// readable only by the user.
+type FileTokenStore struct {
+	Path string
+}
+
+type storedTokens struct {
+	IDToken      string    `json:"id_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Expires      time.Time `json:"expires"`
+}
+
+// DefaultTokenPath returns the path of the token file for the username in the
+// user's configuration directory.
+func DefaultTokenPath(username string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(username))
+
+	return filepath.Join(dir, "wifire", "token-"+hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// Load implements the TokenStore interface.
+func (f FileTokenStore) Load() (idToken, refreshToken string, err error) {
+	b, err := os.ReadFile(f.Path)
+	if err != nil {
+		return "", "", err
+	}
+
+	var t storedTokens
+
+	if err := json.Unmarshal(b, &t); err != nil {
+		return "", "", err
+	}
+
+	return t.IDToken, t.RefreshToken, nil
+}
+
+// Save implements the TokenStore interface.
+func (f FileTokenStore) Save(idToken, refreshToken string, expires time.Time) error {
+	b, err := json.Marshal(storedTokens{
+		IDToken:      idToken,
+		RefreshToken: refreshToken,
+		Expires:      expires,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(f.Path, b, 0o600)
+}
+
+// tokenExpiry returns the expiration time from the exp claim of the JWT.
+func tokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("malformed token")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(claims.Exp, 0), nil
+}
@@ -0,0 +1,101 @@
+package wifire
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestExpiredStoredTokenUsesPassword(t *testing.T) {
+	api := newFakeAPI(t)
+	store := &memTokenStore{idToken: testJWT(time.Now().Add(-time.Hour))}
+
+	w, err := New(append(api.options(), TokenStorage(store))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"InitiateAuth USER_PASSWORD_AUTH"}; !slices.Equal(api.requests(), want) {
+		t.Errorf("got %q, want %q", api.requests(), want)
+	}
+
+	if store.idToken != w.token || store.refreshToken != "refresh" {
+		t.Errorf("new tokens not saved: %q %q", store.idToken, store.refreshToken)
+	}
+}
+
+func TestValidStoredTokenSkipsLogin(t *testing.T) {
+	api := newFakeAPI(t)
+	store := &memTokenStore{idToken: testJWT(time.Now().Add(time.Hour)), refreshToken: "stored"}
+
+	if _, err := New(append(api.options(), TokenStorage(store))...); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := api.requests(); len(got) != 0 {
+		t.Errorf("logged in with a valid stored token: %q", got)
+	}
+}
+
+func TestFileTokenStore(t *testing.T) {
+	f := FileTokenStore{Path: filepath.Join(t.TempDir(), "wifire", "token.json")}
+
+	if err := f.Save("id", "refresh", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("mode %o, want 600", perm)
+	}
+
+	idToken, refreshToken, err := f.Load()
+	if err != nil || idToken != "id" || refreshToken != "refresh" {
+		t.Errorf("got %q %q %v", idToken, refreshToken, err)
+	}
+}
+
+func TestIDToken(t *testing.T) {
+	api := newFakeAPI(t)
+	seeded := testJWT(time.Now().Add(time.Hour))
+
+	w, err := New(URLs(api.URL, api.URL+"/"), IDToken(seeded, "seeded"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.UserData(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"GET /prod/users/self"}; !slices.Equal(api.requests(), want) {
+		t.Errorf("got %q, want %q", api.requests(), want)
+	}
+
+	if w.token != seeded {
+		t.Error("seeded token replaced")
+	}
+}
+
+func TestExpiredIDTokenRefreshes(t *testing.T) {
+	api := newFakeAPI(t)
+
+	w, err := New(URLs(api.URL, api.URL+"/"), IDToken(testJWT(time.Now().Add(-time.Hour)), "seeded"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"InitiateAuth REFRESH_TOKEN_AUTH"}; !slices.Equal(api.requests(), want) {
+		t.Errorf("got %q, want %q", api.requests(), want)
+	}
+
+	if !w.tokenExpires.After(time.Now()) {
+		t.Errorf("token expires %s after the refresh", w.tokenExpires)
+	}
+}
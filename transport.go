@@ -0,0 +1,73 @@
+package wifire
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Transport is the connection to the Traeger service. The default logs in
+// with Cognito, fetches the user data from the WiFire API, and connects to
+// the AWS IoT MQTT broker. MockTransport replays recorded data instead.
+type Transport interface {
+	// Login authenticates the account.
+	Login(ctx context.Context) error
+	// UserData returns the /prod/users/self JSON document.
+	UserData(ctx context.Context) ([]byte, error)
+	// MQTTClient returns an unconnected MQTT client, and when its
+	// credentials expire. A zero expiry never expires. The handlers func
+	// sets the connection handlers (OnConnect, OnConnectionLost, and
+	// OnReconnecting) in the client options.
+	MQTTClient(ctx context.Context, handlers func(*mqtt.ClientOptions)) (mqtt.Client, time.Time, error)
+}
+
+// UseTransport is an option setting function for New(). It replaces the
+// connection to the Traeger service, typically with a MockTransport.
+func UseTransport(t Transport) func(*WiFire) {
+	return func(w *WiFire) {
+		w.config.transport = t
+	}
+}
+
+// cloudTransport is the default Transport to the Traeger service.
+type cloudTransport struct {
+	w *WiFire
+}
+
+func (t cloudTransport) Login(ctx context.Context) error {
+	if t.w.config.tokenStore != nil && t.w.token == "" && t.w.refreshToken == "" {
+		t.w.loadToken()
+	}
+
+	return t.w.ensureValidToken(ctx)
+}
+
+func (t cloudTransport) UserData(ctx context.Context) ([]byte, error) {
+	r, err := t.w.api(ctx, "GET", "/prod/users/self")
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("user data: %s", r.Status)
+	}
+
+	return io.ReadAll(r.Body)
+}
+
+func (t cloudTransport) MQTTClient(ctx context.Context, handlers func(*mqtt.ClientOptions)) (mqtt.Client, time.Time, error) {
+	opts, expires, err := t.w.mqttOptions(ctx)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	handlers(opts)
+
+	return mqtt.NewClient(opts), expires, nil
+}
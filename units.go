@@ -0,0 +1,73 @@
+package wifire
+
+import "math"
+
+// Units is the temperature scale reported by the grill.
+type Units int
+
+// The Units reported by the grill.
+const (
+	Celsius    Units = 0
+	Fahrenheit Units = 1
+)
+
+func (u Units) String() string {
+	if u == Celsius {
+		return "°C"
+	}
+
+	return "°F"
+}
+
+// ConvertTemp converts the temperature v from one Units to another.
+func ConvertTemp(v int, from, to Units) float64 {
+	t := float64(v)
+
+	switch {
+	case from == to:
+		return t
+	case to == Celsius:
+		return (t - 32) * 5 / 9
+	default:
+		return t*9/5 + 32
+	}
+}
+
+// GrillCelsius returns the grill temperature in Celsius.
+func (s Status) GrillCelsius() float64 { return ConvertTemp(s.Grill, s.Units, Celsius) }
+
+// GrillFahrenheit returns the grill temperature in Fahrenheit.
+func (s Status) GrillFahrenheit() float64 { return ConvertTemp(s.Grill, s.Units, Fahrenheit) }
+
+// ProbeCelsius returns the probe temperature in Celsius.
+func (s Status) ProbeCelsius() float64 { return ConvertTemp(s.Probe, s.Units, Celsius) }
+
+// ProbeFahrenheit returns the probe temperature in Fahrenheit.
+func (s Status) ProbeFahrenheit() float64 { return ConvertTemp(s.Probe, s.Units, Fahrenheit) }
+
+// AmbientCelsius returns the ambient temperature in Celsius.
+func (s Status) AmbientCelsius() float64 { return ConvertTemp(s.Ambient, s.Units, Celsius) }
+
+// AmbientFahrenheit returns the ambient temperature in Fahrenheit.
+func (s Status) AmbientFahrenheit() float64 { return ConvertTemp(s.Ambient, s.Units, Fahrenheit) }
+
+// In returns s with its temperatures and set points converted to the Units
+// u, rounded to the nearest degree.
+func (s Status) In(u Units) Status {
+	if s.Units == u {
+		return s
+	}
+
+	convert := func(v int) int {
+		return int(math.Round(ConvertTemp(v, s.Units, u)))
+	}
+
+	s.Ambient = convert(s.Ambient)
+	s.Grill = convert(s.Grill)
+	s.GrillSet = convert(s.GrillSet)
+	s.Probe = convert(s.Probe)
+	s.ProbeSet = convert(s.ProbeSet)
+	s.Units = u
+
+	return s
+}
@@ -0,0 +1,48 @@
+package wifire
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestConvertTemp(t *testing.T) {
+	tests := []struct {
+		v        int
+		from, to Units
+		want     float64
+	}{
+		{212, Fahrenheit, Celsius, 100},
+		{32, Fahrenheit, Celsius, 0},
+		{-40, Fahrenheit, Celsius, -40},
+		{100, Celsius, Fahrenheit, 212},
+		{0, Celsius, Fahrenheit, 32},
+		{225, Fahrenheit, Fahrenheit, 225},
+		{107, Celsius, Celsius, 107},
+	}
+
+	for _, tt := range tests {
+		if got := ConvertTemp(tt.v, tt.from, tt.to); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%d%s in %s: got %g, want %g", tt.v, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestStatusIn(t *testing.T) {
+	f := Status{Ambient: 68, Grill: 225, GrillSet: 225, Probe: 160, ProbeSet: 203, Units: Fahrenheit, PelletLevel: 80}
+
+	c := f.In(Celsius)
+	want := Status{Ambient: 20, Grill: 107, GrillSet: 107, Probe: 71, ProbeSet: 95, Units: Celsius, PelletLevel: 80}
+
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+
+	if same := f.In(Fahrenheit); !reflect.DeepEqual(same, f) {
+		t.Errorf("identity conversion changed %+v to %+v", f, same)
+	}
+
+	if got := c.GrillFahrenheit(); math.Abs(got-224.6) > 1e-9 {
+		t.Errorf("GrillFahrenheit %g, want 224.6", got)
+	}
+}
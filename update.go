@@ -1,43 +1,172 @@
 package wifire
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
+// SystemStatus is the state of the grill.
+type SystemStatus int
+
+// The SystemStatus values reported by the grill.
+const (
+	StatusSleeping   SystemStatus = 2
+	StatusIdle       SystemStatus = 3
+	StatusIgniting   SystemStatus = 4
+	StatusPreheating SystemStatus = 5
+	StatusManualCook SystemStatus = 6
+	StatusCustomCook SystemStatus = 7
+	StatusCoolDown   SystemStatus = 8
+	StatusShutdown   SystemStatus = 9
+	StatusOffline    SystemStatus = 99
+)
+
+func (s SystemStatus) String() string {
+	switch s {
+	case StatusSleeping:
+		return "sleeping"
+	case StatusIdle:
+		return "idle"
+	case StatusIgniting:
+		return "igniting"
+	case StatusPreheating:
+		return "preheating"
+	case StatusManualCook:
+		return "manual cook"
+	case StatusCustomCook:
+		return "custom cook"
+	case StatusCoolDown:
+		return "cool down"
+	case StatusShutdown:
+		return "shutdown"
+	case StatusOffline:
+		return "offline"
+	default:
+		return fmt.Sprintf("status(%d)", int(s))
+	}
+}
+
 // Status is the grill status returned from the MQTT subscription. If there was
 // an error receiving the message the Error field is set.
 type Status struct {
-	Error           error     `json:"error,omitempty"`
-	Ambient         int       `json:"ambient"`
-	Connected       bool      `json:"connected"`
-	Grill           int       `json:"grill"`
-	GrillSet        int       `json:"grill_set"`
-	KeepWarm        int       `json:"keep_warm,omitempty"`
-	PelletLevel     int       `json:"pellet_level,omitempty"`
-	Probe           int       `json:"probe,omitempty"`
-	ProbeAlarmFired bool      `json:"probe_alarm_fired,omitempty"`
-	ProbeConnected  bool      `json:"probe_connected,omitempty"`
-	ProbeSet        int       `json:"probe_set,omitempty"`
-	RealTime        int       `json:"real_time,omitempty"`
-	Smoke           int       `json:"smoke,omitempty"`
-	Time            time.Time `json:"time"`
-	Units           int       `json:"units"`
+	Error           error           `json:"error,omitempty"`
+	Ambient         int             `json:"ambient"`
+	Firmware        string          `json:"firmware,omitempty"`
+	Connected       bool            `json:"connected"`               // the grill's own network link
+	CookElapsed     time.Duration   `json:"cook_elapsed,omitempty"`  // since the cook started, since the first cooking Status seen
+	Errors          int             `json:"errors,omitempty"`        // non-zero when the grill reports an error
+	CurrentCycle    int             `json:"current_cycle,omitempty"` // custom cook program cycle
+	CurrentStep     int             `json:"current_step,omitempty"`  // custom cook program step
+	InCustomCook    bool            `json:"in_custom_cook,omitempty"`
+	Grill           int             `json:"grill"`
+	GrillSet        int             `json:"grill_set"`
+	KeepWarm        bool            `json:"keep_warm,omitempty"`    // transitioning to keep warm
+	PelletLevel     int             `json:"pellet_level,omitempty"` // percent, 0 to 100
+	Probe           int             `json:"probe,omitempty"`
+	ProbeAlarmFired bool            `json:"probe_alarm_fired,omitempty"`
+	ProbeConnected  bool            `json:"probe_connected,omitempty"`
+	ProbeSet        int             `json:"probe_set,omitempty"`
+	RealTime        int             `json:"real_time,omitempty"`
+	ServerOnline    bool            `json:"server_online,omitempty"` // the grill is reachable from the Traeger servers
+	Smoke           int             `json:"smoke,omitempty"`         // non-zero when super smoke is on
+	SystemStatus    SystemStatus    `json:"system_status,omitempty"`
+	Time            time.Time       `json:"time"`
+	TimerStart      time.Time       `json:"timer_start,omitempty"` // zero when no timer is set
+	TimerEnd        time.Time       `json:"timer_end,omitempty"`
+	TimerComplete   bool            `json:"timer_complete,omitempty"`
+	Units           Units           `json:"units"`
+	Raw             json.RawMessage `json:"raw,omitempty"` // the MQTT payload, see RawPayload
+}
+
+// cooking returns true if the grill is lighting or cooking.
+func (s SystemStatus) cooking() bool {
+	switch s {
+	case StatusIgniting, StatusPreheating, StatusManualCook, StatusCustomCook:
+		return true
+	}
+
+	return false
+}
+
+// SuperSmoke returns true if the grill reports super smoke mode is on. Grills
+// without super smoke always report it off.
+func (s Status) SuperSmoke() bool {
+	return s.Smoke != 0
+}
+
+// MarshalJSON encodes a Status for the JSON log. The timers are left out
+// when they are not set rather than written as the zero time.
+func (s Status) MarshalJSON() ([]byte, error) {
+	type plain Status // without the MarshalJSON method
+
+	optional := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+
+		return &t
+	}
+
+	return json.Marshal(struct {
+		plain
+		TimerStart *time.Time `json:"timer_start,omitempty"`
+		TimerEnd   *time.Time `json:"timer_end,omitempty"`
+	}{
+		plain:      plain(s),
+		TimerStart: optional(s.TimerStart),
+		TimerEnd:   optional(s.TimerEnd),
+	})
+}
+
+// UnmarshalJSON decodes a Status from the JSON log. Logs written before
+// KeepWarm was a bool have it as 0 or 1, both forms are accepted.
+func (s *Status) UnmarshalJSON(data []byte) error {
+	type plain Status // without the UnmarshalJSON method
+
+	v := struct {
+		*plain
+		KeepWarm json.RawMessage `json:"keep_warm"`
+	}{plain: (*plain)(s)}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch string(v.KeepWarm) {
+	case "", "null", "false", "0":
+		s.KeepWarm = false
+	case "true", "1":
+		s.KeepWarm = true
+	default:
+		return fmt.Errorf("invalid keep_warm %s", v.KeepWarm)
+	}
+
+	return nil
 }
 
 type prodThingUpdate struct {
-	Status status `json:"status"`
+	Settings settings `json:"settings"`
+	Status   status   `json:"status"`
+	Usage    usage    `json:"usage"`
+}
+
+type settings struct {
+	FirmwareVersion string `json:"fw_version"`
 }
 
 type status struct {
 	Ambient           int    `json:"ambient"` // temperature
 	Connected         bool   `json:"connected"`
 	CookID            string `json:"cook_id"`
-	CooKTimerComplete int    `json:"cook_timer_complete"`
-	CookTimerEnd      int    `json:"cook_timer_end"`
-	CookTimerStrart   int    `json:"cook_timer_start"`
+	CookTimerComplete int    `json:"cook_timer_complete"`
+	CookTimerEnd      int64  `json:"cook_timer_end"`
+	CookTimerStart    int64  `json:"cook_timer_start"`
 	CurrentCycle      int    `json:"current_cycle"`
 	CurrentStep       int    `json:"current_step"`
 	Errors            int    `json:"errors"`
@@ -61,24 +190,285 @@ type status struct {
 	Units             int    `json:"units"`
 }
 
-// SubscribeStatus subscribes to the prod/thing/update for the grill. SubscribeStatus
-// updates are pushed to the returned channel.
-func (g Grill) SubscribeStatus(ch chan Status) error {
-	if !g.client.IsConnected() {
-		if err := g.connect(); err != nil {
-			return err
-		}
+// RawPayload is an option setting function for New(). When enabled the MQTT
+// payload of each update is kept in Status.Raw, for inspecting fields the
+// package does not model. It is off by default.
+func RawPayload(enabled bool) func(*WiFire) {
+	return func(w *WiFire) {
+		w.config.rawPayload = enabled
 	}
+}
 
-	token := g.client.Subscribe("prod/thing/update/"+g.name, 1, func(c mqtt.Client, m mqtt.Message) {
-		ch <- newUpdate(m.Payload())
-	})
+func (g *Grill) updateTopic() string {
+	return "prod/thing/update/" + g.name
+}
+
+// SubscribeStatus subscribes to the prod/thing/update for the grill. Status
+// updates are pushed to the channel. The MQTT client is never blocked by a
+// slow receiver: when the channel is full the oldest Status in it is dropped,
+// so use a buffered channel. The channel is closed by UnsubscribeStatus,
+// Disconnect, or subscribing again with a different channel, so the caller
+// must not send on or close it.
+func (g *Grill) SubscribeStatus(ch chan Status) error {
+	return subscribeChannel(g, &g.status, ch)
+}
+
+// UnsubscribeStatus stops the SubscribeStatus updates and closes the channel.
+func (g *Grill) UnsubscribeStatus() error {
+	return unsubscribeChannel(g, &g.status)
+}
+
+// AddSubscriber adds ch to the channels receiving Status updates. Any
+// number of channels can be added, alongside SubscribeStatus, and all of
+// them share a single subscription to the update topic. As with
+// SubscribeStatus the oldest Status is dropped when ch is full, and ch is
+// closed by RemoveSubscriber or Disconnect. Adding ch again does nothing.
+func (g *Grill) AddSubscriber(ch chan Status) error {
+	g.update.Lock()
+	defer g.update.Unlock()
+
+	g.mutex.Lock()
+	if slices.ContainsFunc(g.fanout, func(f *subscriber[Status]) bool { return f.ch == ch }) {
+		g.mutex.Unlock()
+		return nil
+	}
+
+	sub := newSubscriber(ch)
+	_, subscribed := g.subscriptions[g.updateTopic()]
+	g.fanout = append(slices.Clip(g.fanout), sub)
+	g.mutex.Unlock()
 
-	token.Wait()
+	if subscribed {
+		return nil
+	}
+
+	// The update lock is already held, so this is g.subscribe rather than
+	// g.subscribeUpdate.
+	if err := g.subscribe(context.Background(), g.updateTopic(), g.onUpdate); err != nil {
+		g.mutex.Lock()
+		g.fanout = slices.DeleteFunc(slices.Clone(g.fanout), func(f *subscriber[Status]) bool { return f == sub })
+		g.mutex.Unlock()
+
+		return err
+	}
 
 	return nil
 }
 
+// RemoveSubscriber stops the updates to a channel added with AddSubscriber
+// and closes it. The update topic is unsubscribed once nothing uses it.
+func (g *Grill) RemoveSubscriber(ch chan Status) error {
+	var sub *subscriber[Status]
+
+	g.mutex.Lock()
+	g.fanout = slices.DeleteFunc(slices.Clone(g.fanout), func(f *subscriber[Status]) bool {
+		if f.ch == ch {
+			sub = f
+			return true
+		}
+
+		return false
+	})
+	g.mutex.Unlock()
+
+	if sub == nil {
+		return nil
+	}
+
+	sub.stop()
+
+	return g.unsubscribeUpdate()
+}
+
+// WaitForTarget blocks until a Status is received with the probe connected
+// and at or above probeTarget, and returns that Status. It gives up if ctx is
+// canceled. Like Status it does not disturb the other subscriptions.
+func (g *Grill) WaitForTarget(ctx context.Context, probeTarget int) (Status, error) {
+	ch, done, err := g.watch(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	defer done()
+
+	return waitForTarget(ctx, ch, probeTarget)
+}
+
+// Status returns the next Status received from the grill, connecting first
+// if the Grill is not connected. The connection is left open. It does not
+// disturb the other subscriptions, and the update topic is unsubscribed
+// afterwards only if nothing else uses it.
+func (g *Grill) Status(ctx context.Context) (Status, error) {
+	if _, err := g.mqttClient(ctx); errors.Is(err, ErrNotConnected) {
+		if err := g.ConnectContext(ctx); err != nil {
+			return Status{}, err
+		}
+	}
+
+	ch, done, err := g.watch(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	defer done()
+
+	select {
+	case <-ctx.Done():
+		return Status{}, ctx.Err()
+	case s, ok := <-ch:
+		if !ok {
+			return Status{}, ErrNotConnected
+		}
+
+		return s, s.Error
+	}
+}
+
+// watch adds a private channel receiving the Status updates, alongside the
+// other subscriptions. The returned func removes it and must be called when
+// done.
+func (g *Grill) watch(ctx context.Context) (<-chan Status, func(), error) {
+	ch := make(chan Status, 1)
+	w := newSubscriber(ch)
+
+	g.mutex.Lock()
+	g.waiting = append(g.waiting, w)
+	g.mutex.Unlock()
+
+	done := func() {
+		g.mutex.Lock()
+		g.waiting = slices.DeleteFunc(slices.Clone(g.waiting), func(s *subscriber[Status]) bool { return s == w })
+		g.mutex.Unlock()
+
+		_ = g.unsubscribeUpdate()
+	}
+
+	if err := g.subscribeUpdate(ctx); err != nil {
+		done()
+		return nil, nil, err
+	}
+
+	return ch, done, nil
+}
+
+func waitForTarget(ctx context.Context, ch <-chan Status, probeTarget int) (Status, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return Status{}, ctx.Err()
+		case s, ok := <-ch:
+			if !ok {
+				return Status{}, ErrNotConnected
+			}
+
+			if s.Error == nil && s.ProbeConnected && s.Probe >= probeTarget {
+				return s, nil
+			}
+		}
+	}
+}
+
+// cookElapsed sets the CookElapsed of s from the time of the first cooking
+// Status seen. The cook continues through the cool down and ends when the
+// grill goes idle, sleeps, shuts down, or goes offline. The caller must hold
+// the mutex.
+func (g *Grill) cookElapsed(s *Status) {
+	switch {
+	case s.SystemStatus.cooking():
+		if g.cookStart.IsZero() {
+			g.cookStart = s.Time
+		}
+	case s.SystemStatus != StatusCoolDown:
+		g.cookStart = time.Time{}
+	}
+
+	if !g.cookStart.IsZero() {
+		s.CookElapsed = s.Time.Sub(g.cookStart)
+	}
+}
+
+// subscribeUpdate subscribes to the update topic. It holds the update lock so
+// the subscription can't be reordered with an unsubscribeUpdate, the caller
+// must have added its subscriber first.
+func (g *Grill) subscribeUpdate(ctx context.Context) error {
+	g.update.Lock()
+	defer g.update.Unlock()
+
+	return g.subscribe(ctx, g.updateTopic(), g.onUpdate)
+}
+
+// unsubscribeUpdate unsubscribes from the update topic once there are no
+// more status, usage, event, AddSubscriber, or Status subscribers. The idle
+// check and forgetting the subscription happen under one lock, and the update
+// lock keeps a concurrent subscribe from landing before the broker
+// unsubscribe.
+func (g *Grill) unsubscribeUpdate() error {
+	g.update.Lock()
+	defer g.update.Unlock()
+
+	topic := g.updateTopic()
+
+	g.mutex.Lock()
+	idle := g.status == nil && g.usage == nil && g.events == nil && len(g.fanout) == 0 && len(g.waiting) == 0
+	if idle {
+		delete(g.subscriptions, topic)
+	}
+	g.mutex.Unlock()
+
+	if !idle {
+		return nil
+	}
+
+	client, err := g.mqttClient(context.Background())
+	if err != nil {
+		return err
+	}
+
+	return wait(context.Background(), client.Unsubscribe(topic))
+}
+
+// onUpdate handles the prod/thing/update messages for all the subscribers.
+func (g *Grill) onUpdate(_ mqtt.Client, m mqtt.Message) {
+	s := newUpdate(m.Payload())
+
+	if g.wifire.config.rawPayload {
+		s.Raw = append(json.RawMessage(nil), m.Payload()...)
+	}
+
+	var derived []Event
+
+	g.mutex.Lock()
+	if s.Error == nil {
+		g.cookElapsed(&s)
+		g.last = s
+
+		if g.events != nil {
+			derived = g.derive.Update(s)
+		}
+	}
+	status, usage, events := g.status, g.usage, g.events
+	fanout, waiting := g.fanout, g.waiting
+	g.mutex.Unlock()
+
+	if status != nil {
+		status.send(s)
+	}
+
+	for _, f := range fanout {
+		f.send(s)
+	}
+
+	for _, w := range waiting {
+		w.send(s)
+	}
+
+	for _, e := range derived {
+		events.send(e)
+	}
+
+	if usage != nil {
+		usage.send(newUsage(m.Payload()))
+	}
+}
+
 func newUpdate(data []byte) Status {
 	var msg prodThingUpdate
 
@@ -86,20 +476,90 @@ func newUpdate(data []byte) Status {
 		return Status{Error: err}
 	}
 
-	return Status{
+	s := Status{
 		Ambient:         msg.Status.Ambient,
 		Connected:       msg.Status.Connected,
+		CurrentCycle:    msg.Status.CurrentCycle,
+		CurrentStep:     msg.Status.CurrentStep,
+		InCustomCook:    msg.Status.InCustom != 0,
+		Firmware:        msg.Settings.FirmwareVersion,
+		Errors:          msg.Status.Errors,
 		Grill:           msg.Status.Grill,
 		GrillSet:        msg.Status.Set,
-		KeepWarm:        msg.Status.KeepWarm,
-		PelletLevel:     msg.Status.PelletLevel,
+		KeepWarm:        msg.Status.KeepWarm != 0,
+		PelletLevel:     pelletLevel(msg.Status.PelletLevel),
 		Probe:           msg.Status.Probe,
 		ProbeAlarmFired: msg.Status.ProbeAlarmFired != 0,
 		ProbeConnected:  msg.Status.ProbeConnected != 0,
 		ProbeSet:        msg.Status.ProbeSet,
 		RealTime:        msg.Status.RealTime,
+		ServerOnline:    msg.Status.ServerStatus == 1,
 		Smoke:           msg.Status.Smoke,
+		SystemStatus:    SystemStatus(msg.Status.SystemStatus),
 		Time:            time.Unix(msg.Status.Time, 0),
-		Units:           msg.Status.Units,
+		TimerStart:      unixTime(msg.Status.CookTimerStart),
+		TimerEnd:        unixTime(msg.Status.CookTimerEnd),
+		TimerComplete:   msg.Status.CookTimerComplete != 0,
+		Units:           Units(msg.Status.Units),
 	}
+
+	s.Error = s.validate()
+
+	return s
+}
+
+// The plausible temperature range in Fahrenheit, readings outside of it are
+// glitches.
+const (
+	minPlausibleTemp = -60
+	maxPlausibleTemp = 1000
+)
+
+// validate returns an error if any of the temperatures are outside of the
+// plausible range. The probe is only checked when it is connected.
+func (s Status) validate() error {
+	if err := plausible("ambient", s.Ambient, s.Units); err != nil {
+		return err
+	}
+
+	if err := plausible("grill", s.Grill, s.Units); err != nil {
+		return err
+	}
+
+	if s.ProbeConnected {
+		return plausible("probe", s.Probe, s.Units)
+	}
+
+	return nil
+}
+
+func plausible(name string, temp int, u Units) error {
+	if f := ConvertTemp(temp, u, Fahrenheit); f < minPlausibleTemp || f > maxPlausibleTemp {
+		return fmt.Errorf("%s temperature %d%s is out of range", name, temp, u)
+	}
+
+	return nil
+}
+
+// pelletLevel clamps the reported pellet level to a percentage.
+func pelletLevel(level int) int {
+	if level >= 0 && level <= 100 {
+		return level
+	}
+
+	if Logger != nil {
+		Logger(LogWarn, "wifire", fmt.Sprintf("pellet level %d is out of range", level))
+	}
+
+	return min(max(level, 0), 100)
+}
+
+// unixTime converts the unix timestamp t to a time.Time, a zero timestamp is
+// the zero time.
+func unixTime(t int64) time.Time {
+	if t == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(t, 0)
 }
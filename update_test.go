@@ -0,0 +1,507 @@
+package wifire
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewUpdateKeepWarm(t *testing.T) {
+	for payload, want := range map[string]bool{
+		`{"status":{"keepwarm":1,"time":1720094400}}`: true,
+		`{"status":{"keepwarm":0,"time":1720094400}}`: false,
+	} {
+		if s := newUpdate([]byte(payload)); s.KeepWarm != want {
+			t.Errorf("%s: KeepWarm %t, want %t", payload, s.KeepWarm, want)
+		}
+	}
+}
+
+func TestStatusUnmarshalKeepWarm(t *testing.T) {
+	for line, want := range map[string]bool{
+		`{"grill":225,"keep_warm":1}`:    true,
+		`{"grill":225,"keep_warm":0}`:    false,
+		`{"grill":225,"keep_warm":true}`: true,
+		`{"grill":225}`:                  false,
+	} {
+		var s Status
+
+		if err := json.Unmarshal([]byte(line), &s); err != nil {
+			t.Errorf("%s: %s", line, err)
+			continue
+		}
+
+		if s.KeepWarm != want || s.Grill != 225 {
+			t.Errorf("%s: got KeepWarm %t grill %d", line, s.KeepWarm, s.Grill)
+		}
+	}
+
+	var s Status
+	if err := json.Unmarshal([]byte(`{"keep_warm":"yes"}`), &s); err == nil {
+		t.Error("keep_warm \"yes\" accepted")
+	}
+}
+
+func TestStatusRoundTrip(t *testing.T) {
+	in := Status{Grill: 225, GrillSet: 225, KeepWarm: true, Time: time.Date(2024, 7, 4, 12, 0, 0, 0, time.UTC), Units: Fahrenheit}
+
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out Status
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !out.Time.Equal(in.Time) || out.Grill != in.Grill || !out.KeepWarm {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestNewUpdateTimer(t *testing.T) {
+	s := newUpdate([]byte(`{"status":{"cook_timer_start":1720094400,"cook_timer_end":1720098000,"time":1720094400}}`))
+
+	if !s.TimerStart.Equal(time.Unix(1720094400, 0)) || !s.TimerEnd.Equal(time.Unix(1720098000, 0)) {
+		t.Errorf("timer %s to %s", s.TimerStart, s.TimerEnd)
+	}
+
+	s = newUpdate([]byte(`{"status":{"cook_timer_start":0,"cook_timer_end":0,"time":1720094400}}`))
+
+	if !s.TimerStart.IsZero() || !s.TimerEnd.IsZero() {
+		t.Errorf("unset timer decoded as %s to %s", s.TimerStart, s.TimerEnd)
+	}
+}
+
+func TestStatusMarshalTimer(t *testing.T) {
+	b, err := json.Marshal(Status{Grill: 225})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(b), "timer_") {
+		t.Errorf("unset timers marshaled: %s", b)
+	}
+
+	end := time.Date(2024, 7, 4, 13, 0, 0, 0, time.UTC)
+
+	b, err = json.Marshal(Status{Grill: 225, TimerEnd: end})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(b), `"timer_end":"2024-07-04T13:00:00Z"`) || strings.Contains(string(b), "timer_start") {
+		t.Errorf("got %s", b)
+	}
+
+	var s Status
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.TimerEnd.Equal(end) || !s.TimerStart.IsZero() {
+		t.Errorf("round trip timer %s to %s", s.TimerStart, s.TimerEnd)
+	}
+}
+
+func TestUnsubscribeStatus(t *testing.T) {
+	g, ft := newFakeGrill(t)
+
+	ch := make(chan Status, 1)
+	if err := g.SubscribeStatus(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.UnsubscribeStatus(); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.client().recorded("unsubscribe")
+	if len(calls) != 1 || calls[0].topic != "prod/thing/update/fake" {
+		t.Errorf("unsubscribed from %v, want prod/thing/update/fake", calls)
+	}
+
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed")
+	}
+
+	if err := g.UnsubscribeStatus(); err != nil { // nothing to do
+		t.Error(err)
+	}
+}
+
+func TestWaitForTargetKeepsSubscribeStatus(t *testing.T) {
+	g, ft := newFakeGrill(t)
+
+	ch := make(chan Status, 10)
+	if err := g.SubscribeStatus(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	done := make(chan Status)
+	go func() {
+		s, err := g.WaitForTarget(ctx, 150)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- s
+	}()
+
+	eventually(t, func() bool {
+		g.mutex.RLock()
+		defer g.mutex.RUnlock()
+
+		return len(g.waiting) == 1
+	})
+
+	now := time.Now().Truncate(time.Second)
+	deliverStatus(t, g, ft, Status{Time: now, Grill: 225, ProbeConnected: true, Probe: 160, SystemStatus: StatusManualCook})
+
+	if s := <-done; s.Probe != 160 {
+		t.Errorf("WaitForTarget returned probe %d, want 160", s.Probe)
+	}
+
+	deliverStatus(t, g, ft, Status{Time: now.Add(time.Second), Grill: 226, SystemStatus: StatusManualCook})
+
+	for _, want := range []int{225, 226} {
+		select {
+		case s, ok := <-ch:
+			if !ok {
+				t.Fatal("SubscribeStatus channel closed by WaitForTarget")
+			}
+
+			if s.Grill != want {
+				t.Errorf("grill %d, want %d", s.Grill, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("SubscribeStatus channel stopped receiving")
+		}
+	}
+
+	if calls := ft.client().recorded("unsubscribe"); len(calls) != 0 {
+		t.Errorf("update topic unsubscribed while SubscribeStatus is active: %v", calls)
+	}
+}
+
+func TestNewUpdateSmokePellets(t *testing.T) {
+	s := newUpdate([]byte(`{"status":{"smoke":1,"pellet_level":75,"time":1720094400}}`))
+
+	if !s.SuperSmoke() || s.PelletLevel != 75 {
+		t.Errorf("SuperSmoke %t, PelletLevel %d", s.SuperSmoke(), s.PelletLevel)
+	}
+
+	if s = newUpdate([]byte(`{"status":{"time":1720094400}}`)); s.SuperSmoke() {
+		t.Error("SuperSmoke on without smoke")
+	}
+
+	var warnings []string
+
+	prev := Logger
+	Logger = func(_ LogLevel, _, message string) { warnings = append(warnings, message) }
+	t.Cleanup(func() { Logger = prev })
+
+	for level, want := range map[int]int{-5: 0, 0: 0, 100: 100, 130: 100} {
+		if got := pelletLevel(level); got != want {
+			t.Errorf("pelletLevel(%d) = %d, want %d", level, got, want)
+		}
+	}
+
+	if len(warnings) != 2 {
+		t.Errorf("%d warnings for two out of range levels: %q", len(warnings), warnings)
+	}
+}
+
+func TestRawPayload(t *testing.T) {
+	in := Status{Grill: 225, GrillSet: 225, Time: time.Unix(1720094400, 0), Units: Fahrenheit}
+
+	for _, enabled := range []bool{true, false} {
+		g, ft := newFakeGrill(t, RawPayload(enabled))
+
+		ch := make(chan Status, 1)
+		if err := g.SubscribeStatus(ch); err != nil {
+			t.Fatal(err)
+		}
+
+		deliverStatus(t, g, ft, in)
+
+		s := <-ch
+
+		if enabled && !bytes.Equal(s.Raw, mockPayload(in)) {
+			t.Errorf("Raw %s, want %s", s.Raw, mockPayload(in))
+		}
+
+		if !enabled && s.Raw != nil {
+			t.Errorf("Raw %s when disabled", s.Raw)
+		}
+	}
+}
+
+func TestNewUpdateCustomCook(t *testing.T) {
+	payload := `{"status":{"in_custom":1,"current_cycle":2,"current_step":3,"system_status":` +
+		strconv.Itoa(int(StatusCustomCook)) + `,"grill":250,"set":250,"time":1720094400}}`
+
+	s := newUpdate([]byte(payload))
+
+	if s.Error != nil || !s.InCustomCook || s.CurrentCycle != 2 || s.CurrentStep != 3 || s.SystemStatus != StatusCustomCook {
+		t.Errorf("got %+v", s)
+	}
+
+	if s = newUpdate([]byte(`{"status":{"in_custom":0,"time":1720094400}}`)); s.InCustomCook || s.CurrentCycle != 0 {
+		t.Errorf("manual cook decoded as custom: %+v", s)
+	}
+}
+
+func TestNewUpdateServerOnline(t *testing.T) {
+	for payload, want := range map[string][2]bool{ // Connected, ServerOnline
+		`{"status":{"connected":true,"server_status":0,"time":1720094400}}`:  {true, false},
+		`{"status":{"connected":false,"server_status":1,"time":1720094400}}`: {false, true},
+		`{"status":{"connected":true,"server_status":1,"time":1720094400}}`:  {true, true},
+	} {
+		if s := newUpdate([]byte(payload)); s.Connected != want[0] || s.ServerOnline != want[1] {
+			t.Errorf("%s: Connected %t ServerOnline %t", payload, s.Connected, s.ServerOnline)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	g, ft := newFakeGrill(t)
+
+	type result struct {
+		s   Status
+		err error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		s, err := g.Status(context.Background())
+		done <- result{s, err}
+	}()
+
+	eventually(t, func() bool {
+		return ft.client().deliver(g.updateTopic(), mockPayload(Status{Grill: 225, GrillSet: 250, Time: t0, Units: Fahrenheit}))
+	})
+
+	r := <-done
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+
+	if r.s.Grill != 225 || r.s.GrillSet != 250 {
+		t.Errorf("got %+v", r.s)
+	}
+
+	if calls := ft.client().recorded("unsubscribe"); len(calls) != 1 || calls[0].topic != g.updateTopic() {
+		t.Errorf("unsubscribed from %v, want %s", calls, g.updateTopic())
+	}
+
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	if len(g.waiting) != 0 {
+		t.Errorf("%d private subscribers left", len(g.waiting))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		s     Status
+		valid bool
+	}{
+		{Status{Grill: 225, Ambient: 70, Units: Fahrenheit}, true},
+		{Status{Grill: 1200, Ambient: 70, Units: Fahrenheit}, false},
+		{Status{Grill: 225, Ambient: -80, Units: Fahrenheit}, false},
+		{Status{Grill: 500, Ambient: 20, Units: Celsius}, true}, // 932°F
+		{Status{Grill: 600, Ambient: 20, Units: Celsius}, false},
+		{Status{Grill: 225, Probe: 2000, Units: Fahrenheit}, true}, // disconnected probe
+		{Status{Grill: 225, Probe: 2000, ProbeConnected: true, Units: Fahrenheit}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.s.validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v: got %v", tt.s, err)
+		}
+	}
+}
+
+func TestOutOfRangeUpdate(t *testing.T) {
+	g, ft := newFakeGrill(t)
+
+	ch := make(chan Status, 1)
+	if err := g.SubscribeStatus(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.SubscribeEvents(make(chan Event, 4)); err != nil {
+		t.Fatal(err)
+	}
+
+	cooking := Status{Grill: 225, GrillSet: 225, Time: t0, SystemStatus: StatusManualCook, Units: Celsius}
+
+	deliverStatus(t, g, ft, cooking)
+	<-ch
+
+	glitch := cooking
+	glitch.Grill = 900
+	glitch.Time = t0.Add(10 * time.Minute)
+
+	deliverStatus(t, g, ft, glitch)
+
+	if s := <-ch; s.Error == nil || s.CookElapsed != 0 {
+		t.Errorf("glitch: error %v, cook elapsed %s", s.Error, s.CookElapsed)
+	}
+
+	g.mutex.RLock()
+	last, detected := g.last, g.derive.last
+	g.mutex.RUnlock()
+
+	if last.Grill != 225 || detected.Grill != 225 {
+		t.Errorf("last status grill %d, events grill %d, want the glitch ignored", last.Grill, detected.Grill)
+	}
+
+	cooking.Time = t0.Add(20 * time.Minute)
+	deliverStatus(t, g, ft, cooking)
+
+	if s := <-ch; s.Error != nil || s.CookElapsed != 20*time.Minute {
+		t.Errorf("after the glitch: error %v, cook elapsed %s", s.Error, s.CookElapsed)
+	}
+}
+
+func TestCookElapsed(t *testing.T) {
+	g, ft := newFakeGrill(t)
+
+	ch := make(chan Status, 1)
+	if err := g.SubscribeStatus(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		minute  int
+		state   SystemStatus
+		elapsed time.Duration
+	}{
+		{0, StatusPreheating, 0},
+		{30, StatusManualCook, 30 * time.Minute},
+		{60, StatusCoolDown, 60 * time.Minute}, // still part of the cook
+		{70, StatusSleeping, 0},
+		{80, StatusIgniting, 0}, // a new cook
+		{90, StatusManualCook, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		deliverStatus(t, g, ft, Status{Grill: 225, Time: t0.Add(time.Duration(tt.minute) * time.Minute), SystemStatus: tt.state, Units: Fahrenheit})
+
+		if s := <-ch; s.CookElapsed != tt.elapsed {
+			t.Errorf("%dm %s: cook elapsed %s, want %s", tt.minute, tt.state, s.CookElapsed, tt.elapsed)
+		}
+	}
+}
+
+func TestAddSubscriber(t *testing.T) {
+	g, ft := newFakeGrill(t)
+
+	a, b := make(chan Status, 3), make(chan Status, 3)
+
+	for _, ch := range []chan Status{a, b, a} { // adding a again does nothing
+		if err := g.AddSubscriber(ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if calls := ft.client().recorded("subscribe"); len(calls) != 1 {
+		t.Errorf("%d subscriptions, want 1", len(calls))
+	}
+
+	for _, grill := range []int{200, 210, 220} {
+		deliverStatus(t, g, ft, Status{Grill: grill, Time: t0, Units: Fahrenheit})
+	}
+
+	for name, ch := range map[string]chan Status{"a": a, "b": b} {
+		for _, want := range []int{200, 210, 220} {
+			if s := <-ch; s.Grill != want {
+				t.Errorf("%s: grill %d, want %d", name, s.Grill, want)
+			}
+		}
+	}
+
+	if err := g.RemoveSubscriber(a); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := <-a; ok {
+		t.Error("removed channel not closed")
+	}
+
+	if calls := ft.client().recorded("unsubscribe"); len(calls) != 0 {
+		t.Errorf("unsubscribed while b is still subscribed: %v", calls)
+	}
+
+	if err := g.RemoveSubscriber(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls := ft.client().recorded("unsubscribe"); len(calls) != 1 {
+		t.Errorf("%d unsubscribes after the last subscriber, want 1", len(calls))
+	}
+}
+
+func TestAddSubscriberRacesUnsubscribe(t *testing.T) {
+	g, ft := newFakeGrill(t)
+
+	// While ch is added the broker must stay subscribed to the update topic,
+	// whatever SubscribeStatus and UnsubscribeStatus do alongside.
+	subscribed := func() bool {
+		c := ft.client()
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		return c.handlers[g.updateTopic()] != nil
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 500; i++ {
+			if err := g.SubscribeStatus(make(chan Status, 1)); err != nil {
+				t.Error(err)
+				return
+			}
+
+			if err := g.UnsubscribeStatus(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 500; i++ {
+		ch := make(chan Status, 1)
+
+		if err := g.AddSubscriber(ch); err != nil {
+			t.Fatal(err)
+		}
+
+		if !subscribed() {
+			t.Fatalf("round %d: added a subscriber but the update topic is unsubscribed", i)
+		}
+
+		if err := g.RemoveSubscriber(ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wg.Wait()
+}
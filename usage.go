@@ -0,0 +1,120 @@
+package wifire
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Usage is the grill usage and maintenance information returned from the
+// MQTT subscription. If there was an error receiving the message the Error
+// field is set.
+type Usage struct {
+	Error                    error         `json:"error,omitempty"`
+	Auger                    int           `json:"auger"` // auger cycles
+	CookCycles               int           `json:"cook_cycles"`
+	ErrorStats               ErrorStats    `json:"error_stats"`
+	Fan                      int           `json:"fan"`
+	GreaseTrapCleanCountdown int           `json:"grease_trap_clean_countdown"`
+	GrillCleanCountdown      int           `json:"grill_clean_countdown"`
+	HotRod                   int           `json:"hotrod"`
+	RunTime                  time.Duration `json:"runtime"`
+	Time                     time.Time     `json:"time"`
+}
+
+// EstimatedPelletsUsed returns the pellets burned over the grill's lifetime
+// RunTime at the rate perHour, in the rate's units (e.g. pounds per hour).
+// Actual use varies with the cook temperature and the weather.
+func (u Usage) EstimatedPelletsUsed(perHour float64) float64 {
+	return u.RunTime.Hours() * perHour
+}
+
+// PelletsUsedSince returns the estimated pellets burned at the rate perHour
+// between the earlier Usage prev (e.g. when the hopper was filled) and u.
+func (u Usage) PelletsUsedSince(prev Usage, perHour float64) float64 {
+	return max(u.EstimatedPelletsUsed(perHour)-prev.EstimatedPelletsUsed(perHour), 0)
+}
+
+// ErrorStats are the fault counters reported by the grill.
+type ErrorStats struct {
+	AugerDisconnect   int `json:"auger_disconnect"`
+	AugerOverCurrent  int `json:"auger_overcurrent"`
+	BadThermocouple   int `json:"bad_thermocouple"`
+	FanDisconnect     int `json:"fan_disconnect"`
+	IgniterDisconnect int `json:"igniter_disconnect"`
+	LowAmbient        int `json:"low_ambient"`
+	LowTemp           int `json:"low_temp"`
+	OverHeat          int `json:"overheat"`
+}
+
+// HasFaults returns true if any of the fault counters are non-zero.
+func (e ErrorStats) HasFaults() bool {
+	return e != ErrorStats{}
+}
+
+type usage struct {
+	Auger                    int        `json:"auger"`
+	CookCycles               int        `json:"cook_cycles"`
+	ErrorStats               errorStats `json:"error_stats"`
+	Fan                      int        `json:"fan"`
+	GreaseTrapCleanCountdown int        `json:"grease_trap_clean_countdown"`
+	GrillCleanCountdown      int        `json:"grill_clean_countdown"`
+	HotRod                   int        `json:"hotrod"`
+	RunTime                  int        `json:"runtime"` // seconds
+	Time                     int64      `json:"time"`
+}
+
+type errorStats struct {
+	AugerDisco      int `json:"auger_disco"`
+	AugerOvercur    int `json:"auger_ovrcur"`
+	BadThermocouple int `json:"bad_thermocouple"`
+	FanDisco        int `json:"fan_disco"`
+	IgnDisco        int `json:"ign_disco"`
+	LowAmbient      int `json:"low_ambient"`
+	LowTemp         int `json:"lowtemp"`
+	OverHeat        int `json:"overheat"`
+}
+
+// SubscribeUsage subscribes to the prod/thing/update for the grill. Usage
+// updates are pushed to the channel, dropping the oldest when it is full, and
+// the channel is closed when unsubscribed as with SubscribeStatus.
+func (g *Grill) SubscribeUsage(ch chan Usage) error {
+	return subscribeChannel(g, &g.usage, ch)
+}
+
+// UnsubscribeUsage stops the SubscribeUsage updates and closes the channel.
+func (g *Grill) UnsubscribeUsage() error {
+	return unsubscribeChannel(g, &g.usage)
+}
+
+func newUsage(data []byte) Usage {
+	var msg prodThingUpdate
+
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return Usage{Error: err}
+	}
+
+	return Usage{
+		Auger:                    msg.Usage.Auger,
+		CookCycles:               msg.Usage.CookCycles,
+		ErrorStats:               newErrorStats(msg.Usage.ErrorStats),
+		Fan:                      msg.Usage.Fan,
+		GreaseTrapCleanCountdown: msg.Usage.GreaseTrapCleanCountdown,
+		GrillCleanCountdown:      msg.Usage.GrillCleanCountdown,
+		HotRod:                   msg.Usage.HotRod,
+		RunTime:                  time.Duration(msg.Usage.RunTime) * time.Second,
+		Time:                     unixTime(msg.Usage.Time),
+	}
+}
+
+func newErrorStats(e errorStats) ErrorStats {
+	return ErrorStats{
+		AugerDisconnect:   e.AugerDisco,
+		AugerOverCurrent:  e.AugerOvercur,
+		BadThermocouple:   e.BadThermocouple,
+		FanDisconnect:     e.FanDisco,
+		IgniterDisconnect: e.IgnDisco,
+		LowAmbient:        e.LowAmbient,
+		LowTemp:           e.LowTemp,
+		OverHeat:          e.OverHeat,
+	}
+}
@@ -0,0 +1,129 @@
+package wifire
+
+import (
+	"testing"
+	"time"
+)
+
+// usagePayload is a prod/thing/update message trimmed to the usage block.
+const usagePayload = `{
+  "status": {"grill": 225, "set": 225, "system_status": 6, "time": 1720094400},
+  "usage": {
+    "auger": 38211,
+    "cook_cycles": 152,
+    "error_stats": {
+      "auger_disco": 0,
+      "auger_ovrcur": 1,
+      "bad_thermocouple": 0,
+      "fan_disco": 0,
+      "ign_disco": 2,
+      "low_ambient": 0,
+      "lowtemp": 3,
+      "overheat": 0
+    },
+    "fan": 245671,
+    "grease_trap_clean_countdown": 1440,
+    "grill_clean_countdown": 2880,
+    "hotrod": 9876,
+    "runtime": 864000,
+    "time": 1720094400
+  }
+}`
+
+func TestNewUsage(t *testing.T) {
+	u := newUsage([]byte(usagePayload))
+	if u.Error != nil {
+		t.Fatal(u.Error)
+	}
+
+	want := Usage{
+		Auger:                    38211,
+		CookCycles:               152,
+		ErrorStats:               ErrorStats{AugerOverCurrent: 1, IgniterDisconnect: 2, LowTemp: 3},
+		Fan:                      245671,
+		GreaseTrapCleanCountdown: 1440,
+		GrillCleanCountdown:      2880,
+		HotRod:                   9876,
+		RunTime:                  240 * time.Hour,
+		Time:                     time.Unix(1720094400, 0),
+	}
+
+	if u != want {
+		t.Errorf("got %+v, want %+v", u, want)
+	}
+}
+
+func TestNewUsageInvalid(t *testing.T) {
+	if u := newUsage([]byte("{")); u.Error == nil {
+		t.Error("no error for an invalid payload")
+	}
+}
+
+func TestHasFaults(t *testing.T) {
+	tests := []struct {
+		e    ErrorStats
+		want bool
+	}{
+		{ErrorStats{}, false},
+		{ErrorStats{AugerDisconnect: 1}, true},
+		{ErrorStats{AugerOverCurrent: 1}, true},
+		{ErrorStats{BadThermocouple: 1}, true},
+		{ErrorStats{FanDisconnect: 1}, true},
+		{ErrorStats{IgniterDisconnect: 1}, true},
+		{ErrorStats{LowAmbient: 1}, true},
+		{ErrorStats{LowTemp: 1}, true},
+		{ErrorStats{OverHeat: 1}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.e.HasFaults(); got != tt.want {
+			t.Errorf("%+v: got %t, want %t", tt.e, got, tt.want)
+		}
+	}
+}
+
+func TestNewErrorStats(t *testing.T) {
+	got := newErrorStats(errorStats{
+		AugerDisco:      1,
+		AugerOvercur:    2,
+		BadThermocouple: 3,
+		FanDisco:        4,
+		IgnDisco:        5,
+		LowAmbient:      6,
+		LowTemp:         7,
+		OverHeat:        8,
+	})
+
+	want := ErrorStats{
+		AugerDisconnect:   1,
+		AugerOverCurrent:  2,
+		BadThermocouple:   3,
+		FanDisconnect:     4,
+		IgniterDisconnect: 5,
+		LowAmbient:        6,
+		LowTemp:           7,
+		OverHeat:          8,
+	}
+
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestPelletsUsed(t *testing.T) {
+	u := newUsage([]byte(usagePayload)) // 240 hours of runtime
+
+	if got := u.EstimatedPelletsUsed(2); got != 480 {
+		t.Errorf("EstimatedPelletsUsed %g, want 480", got)
+	}
+
+	filled := Usage{RunTime: 234*time.Hour + 30*time.Minute}
+
+	if got := u.PelletsUsedSince(filled, 2); got != 11 {
+		t.Errorf("PelletsUsedSince %g, want 11", got)
+	}
+
+	if got := filled.PelletsUsedSince(u, 2); got != 0 {
+		t.Errorf("PelletsUsedSince a later Usage %g, want 0", got)
+	}
+}
@@ -1,8 +1,8 @@
 package wifire
 
 import (
+	"context"
 	"encoding/json"
-	"net/http"
 )
 
 type getUserDataResponse struct {
@@ -31,53 +31,54 @@ type thing struct {
 	UserID       string     `json:"userId"`
 	Status       string     `json:"status"`
 	ProductID    string     `json:"productId"`
-	GrillModel   grillModel `json:"grillModel"`
+	GrillModel   GrillModel `json:"grillModel"`
 }
 
-type grillModel struct {
-	ModuelNumber       string `json:"modelNumber"`
+// GrillModel is the model information for a grill from the WiFire API.
+type GrillModel struct {
+	ModelNumber        string `json:"modelNumber"`
 	Group              string `json:"group"`
 	IOTCapable         bool   `json:"iotCapable"`
 	Make               string `json:"make"`
 	IsTraeger          bool   `json:"isTraegerBrand"`
 	Region             string `json:"regionIso"`
 	DeviceTypeID       string `json:"deviceTypeId"`
-	Image              image  `json:"image"`
+	Image              Image  `json:"image"`
 	OwnersManualURL    string `json:"ownersManualUrl"`
 	Name               string `json:"name"`
 	Description        string `json:"description"`
 	ReferenceProductID string `json:"referenceProductId"`
 }
 
-type image struct {
+// Image is the location of a grill model image.
+type Image struct {
 	DefaultHost string `json:"defaultHost"`
 	Endpoint    string `json:"endpoint"`
 	Name        string `json:"name"`
 }
 
 // UserData fetches the /prod/users/self information from the WiFire API.
-func (w WiFire) UserData() (*getUserDataResponse, error) { //nolint:revive // response is read only user doesn't need to create a new struct
-	client := http.Client{}
-
-	req, err := http.NewRequest("GET", w.config.baseURL+"/prod/users/self", http.NoBody)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("authorization", w.token)
+func (w *WiFire) UserData() (*getUserDataResponse, error) { //nolint:revive // response is read only user doesn't need to create a new struct
+	return w.UserDataContext(context.Background())
+}
 
-	r, err := client.Do(req)
+// UserDataContext is like UserData but the request is aborted if ctx is
+// canceled.
+func (w *WiFire) UserDataContext(ctx context.Context) (*getUserDataResponse, error) { //nolint:revive // see UserData
+	b, err := w.config.transport.UserData(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	defer r.Body.Close()
-
 	var data getUserDataResponse
 
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+	if err := json.Unmarshal(b, &data); err != nil {
 		return nil, err
 	}
 
+	w.mutex.Lock()
+	w.things = data.Things
+	w.mutex.Unlock()
+
 	return &data, nil
 }
@@ -5,45 +5,91 @@ package wifire
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Errors returned when logging into the WiFire API.
+var (
+	// ErrInvalidCredentials is returned when Cognito rejects the username or
+	// password.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrCognitoUnavailable is returned when Cognito cannot be reached or
+	// fails to handle the request.
+	ErrCognitoUnavailable = errors.New("cognito unavailable")
 )
 
 // WiFire is a handle for the WiFire API connection.
 type WiFire struct {
+	mutex        sync.Mutex
 	token        string
 	tokenExpires time.Time
+	refreshToken string
+	accessToken  string
+	grills       []*Grill
+	things       []thing // from the last UserData
 	config       config
 }
 
 type config struct {
-	username   string
-	password   string
-	cognitoURL string
-	baseURL    string
-	clientID   string
+	username        string
+	password        string
+	region          string
+	cognitoURL      string
+	baseURL         string
+	clientID        string
+	refreshSkew     time.Duration
+	httpClient      *http.Client
+	authFlow        AuthFlow
+	userPoolID      string
+	tokenStore      TokenStore
+	maxAttempts     int
+	retryBase       time.Duration
+	mqttOptions     func(*mqtt.ClientOptions)
+	mqttKeepAlive   time.Duration
+	mqttPingTimeout time.Duration
+	qos             byte
+	rawPayload      bool
+	transport       Transport
+	dryRun          func(topic string, payload []byte)
 }
 
 var defaultConfig = config{
-	cognitoURL: "https://cognito-idp.us-west-2.amazonaws.com/",
-	baseURL:    "https://1ywgyc65d1.execute-api.us-west-2.amazonaws.com",
-	clientID:   "2fuohjtqv1e63dckp5v84rau0j",
+	region:      "us-west-2",
+	baseURL:     "https://1ywgyc65d1.execute-api.us-west-2.amazonaws.com",
+	clientID:    "2fuohjtqv1e63dckp5v84rau0j",
+	refreshSkew: 5 * time.Minute,
+	httpClient:  &http.Client{Timeout: 30 * time.Second},
+	maxAttempts: 3,
+	retryBase:   500 * time.Millisecond,
+	qos:         1,
 }
 
 type requestTokenBody struct {
-	AuthFlow       string         `json:"AuthFlow"`
-	AuthParameters authParameters `json:"AuthParameters"`
-	ClientID       string         `json:"ClientId"`
-}
-
-type authParameters struct {
-	Username string `json:"USERNAME"`
-	Password string `json:"PASSWORD"`
+	AuthFlow       string            `json:"AuthFlow"`
+	AuthParameters map[string]string `json:"AuthParameters"`
+	ClientID       string            `json:"ClientId"`
 }
 
 type requestTokenResponse struct {
 	AuthenticationResult authenticationResult
+	ChallengeName        string            `json:"ChallengeName"`
+	ChallengeParameters  map[string]string `json:"ChallengeParameters"`
+}
+
+// cognitoError is the body Cognito returns for a failed request.
+type cognitoError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
 }
 
 type authenticationResult struct {
@@ -73,6 +119,15 @@ func ClientID(id string) func(*WiFire) {
 	}
 }
 
+// Region is an option setting function for New(). It sets the AWS region of
+// the Cognito service used to obtain a token. The default is "us-west-2".
+// This is ignored if the Cognito URL is set with URLs.
+func Region(region string) func(*WiFire) {
+	return func(w *WiFire) {
+		w.config.region = region
+	}
+}
+
 // URLs is an option setting function for New(). It sets the WiFire API URLs
 // used to pull the user information and obtain a token.
 func URLs(base, cognito string) func(*WiFire) {
@@ -82,63 +137,314 @@ func URLs(base, cognito string) func(*WiFire) {
 	}
 }
 
+// TokenRefreshSkew is an option setting function for New(). It sets how long
+// before the ID token expires it is refreshed. The default is five minutes.
+func TokenRefreshSkew(d time.Duration) func(*WiFire) {
+	return func(w *WiFire) {
+		w.config.refreshSkew = d
+	}
+}
+
+// HTTPClient is an option setting function for New(). It sets the HTTP client
+// used for all the REST calls. The default client has a 30 second timeout.
+func HTTPClient(c *http.Client) func(*WiFire) {
+	return func(w *WiFire) {
+		w.config.httpClient = c
+	}
+}
+
 // New returns a new WiFire connection or an error.
 func New(opts ...func(*WiFire)) (*WiFire, error) {
+	return NewContext(context.Background(), opts...)
+}
+
+// NewContext is like New but the login is aborted if ctx is canceled.
+func NewContext(ctx context.Context, opts ...func(*WiFire)) (*WiFire, error) {
 	w := WiFire{config: defaultConfig}
 
 	for _, o := range opts {
 		o(&w)
 	}
 
-	if err := w.refresh(); err != nil {
+	if err := w.config.validate(); err != nil {
+		return nil, err
+	}
+
+	if w.config.transport == nil {
+		w.config.transport = cloudTransport{w: &w}
+	}
+
+	if err := w.config.transport.Login(ctx); err != nil {
 		return nil, err
 	}
 
 	return &w, nil
+}
+
+// loadToken restores the tokens from the TokenStore. Any error is ignored
+// since the login falls back to the password.
+func (w *WiFire) loadToken() {
+	idToken, refreshToken, err := w.config.tokenStore.Load()
+	if err != nil {
+		return
+	}
+
+	w.refreshToken = refreshToken
 
+	if expires, err := tokenExpiry(idToken); err == nil {
+		w.token = idToken
+		w.tokenExpires = expires
+	}
 }
 
-func (w *WiFire) refresh() error {
+// validate checks the configuration and fills in the Cognito URL from the
+// region if it was not set.
+func (c *config) validate() error {
+	if c.cognitoURL == "" {
+		if c.region == "" {
+			return errors.New("region is not set")
+		}
+
+		c.cognitoURL = "https://cognito-idp." + c.region + ".amazonaws.com/"
+	}
+
+	for _, u := range []string{c.baseURL, c.cognitoURL} {
+		p, err := url.Parse(u)
+		if err != nil {
+			return fmt.Errorf("invalid URL %q: %w", u, err)
+		}
+
+		if p.Scheme == "" || p.Host == "" {
+			return fmt.Errorf("invalid URL %q: missing scheme or host", u)
+		}
+	}
+
+	return nil
+}
+
+// idToken returns a valid ID token, refreshing it first if it is about to
+// expire.
+func (w *WiFire) idToken(ctx context.Context) (string, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.ensureValidToken(ctx); err != nil {
+		return "", err
+	}
+
+	return w.token, nil
+}
+
+// ensureValidToken refreshes the ID token if it expires within the refresh
+// skew. The refresh token is tried first, falling back to the username and
+// password. The caller must hold the mutex.
+func (w *WiFire) ensureValidToken(ctx context.Context) error {
+	if time.Until(w.tokenExpires) > w.config.refreshSkew {
+		return nil
+	}
+
+	if w.refreshToken != "" {
+		err := w.initiateAuth(ctx, "REFRESH_TOKEN_AUTH", map[string]string{
+			"REFRESH_TOKEN": w.refreshToken,
+		})
+		if err == nil {
+			return nil
+		}
+
+		if Logger != nil {
+			Logger(LogWarn, "wifire", "cannot refresh token: "+redact(err.Error()))
+		}
+	}
+
+	return w.login(ctx)
+}
+
+// login obtains a new token using the username and password.
+func (w *WiFire) login(ctx context.Context) error {
+	if w.config.authFlow == SRPAuth {
+		return w.loginSRP(ctx)
+	}
+
+	return w.initiateAuth(ctx, "USER_PASSWORD_AUTH", map[string]string{
+		"USERNAME": w.config.username,
+		"PASSWORD": w.config.password,
+	})
+}
+
+func (w *WiFire) initiateAuth(ctx context.Context, flow string, params map[string]string) error {
 	body := requestTokenBody{
-		AuthFlow: "USER_PASSWORD_AUTH",
-		AuthParameters: authParameters{
-			Username: w.config.username,
-			Password: w.config.password,
-		},
-		ClientID: w.config.clientID,
+		AuthFlow:       flow,
+		AuthParameters: params,
+		ClientID:       w.config.clientID,
 	}
 
-	b, err := json.Marshal(body)
-	if err != nil {
+	t0 := time.Now()
+
+	var auth requestTokenResponse
+
+	if err := w.cognito(ctx, "InitiateAuth", body, &auth); err != nil {
 		return err
 	}
 
-	client := http.Client{}
-	req, err := http.NewRequest("POST", w.config.cognitoURL, bytes.NewReader(b))
+	w.setToken(t0, auth.AuthenticationResult)
+
+	return nil
+}
+
+// setToken saves the tokens from the authentication result, t0 is when the
+// request was made.
+func (w *WiFire) setToken(t0 time.Time, result authenticationResult) {
+	w.token = result.IDToken
+	w.accessToken = result.AccessToken
+	w.tokenExpires = t0.Add(time.Second * time.Duration(result.ExpiresIn))
+
+	if result.RefreshToken != "" { // not returned when refreshing
+		w.refreshToken = result.RefreshToken
+	}
+
+	if w.config.tokenStore != nil {
+		if err := w.config.tokenStore.Save(w.token, w.refreshToken, w.tokenExpires); err != nil && Logger != nil {
+			Logger(LogWarn, "wifire", "cannot save token: "+redact(err.Error()))
+		}
+	}
+}
+
+// Close disconnects all the Grills, signs out of Cognito revoking the
+// tokens, and clears them from memory and the TokenStore. It is safe to
+// call Close more than once. The Grills are disconnected without holding the
+// mutex, so their OnConnectionChange funcs may use the WiFire.
+func (w *WiFire) Close() error {
+	w.mutex.Lock()
+	grills := w.grills
+	accessToken := w.accessToken
+
+	w.grills = nil
+	w.token = ""
+	w.tokenExpires = time.Time{}
+	w.refreshToken = ""
+	w.accessToken = ""
+
+	if w.config.tokenStore != nil {
+		if err := w.config.tokenStore.Save("", "", time.Time{}); err != nil && Logger != nil {
+			Logger(LogWarn, "wifire", "cannot clear token: "+redact(err.Error()))
+		}
+	}
+	w.mutex.Unlock()
+
+	for _, g := range grills {
+		g.Disconnect()
+	}
+
+	// There is no access token to sign out with after IDToken or a
+	// TokenStore reload, the tokens are still cleared.
+	if accessToken == "" {
+		return nil
+	}
+
+	return w.cognito(context.Background(), "GlobalSignOut", map[string]string{
+		"AccessToken": accessToken,
+	}, &struct{}{})
+}
+
+// api sends an authorized request with no body to the WiFire API path. If
+// the token is rejected with a 401 or 403 it is renewed and the request is
+// sent once more.
+func (w *WiFire) api(ctx context.Context, method, path string) (*http.Response, error) {
+	token, err := w.idToken(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
-	req.Header.Set("X-Amz-Target", "AWSCognitoIdentityProviderService.InitiateAuth")
+	r, err := w.authorized(ctx, method, path, token)
+	if err != nil || (r.StatusCode != http.StatusUnauthorized && r.StatusCode != http.StatusForbidden) {
+		return r, err
+	}
 
-	t0 := time.Now()
+	_, _ = io.Copy(io.Discard, r.Body)
+	r.Body.Close()
 
-	r, err := client.Do(req)
+	if Logger != nil {
+		Logger(LogWarn, "wifire", path+" was "+r.Status+", renewing token")
+	}
+
+	if token, err = w.renewToken(ctx); err != nil {
+		return nil, err
+	}
+
+	return w.authorized(ctx, method, path, token)
+}
+
+func (w *WiFire) authorized(ctx context.Context, method, path, token string) (*http.Response, error) {
+	return w.doWithRetry(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, w.config.baseURL+path, http.NoBody)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("authorization", token)
+
+		return req, nil
+	})
+}
+
+// renewToken gets a new ID token even if the current one has not expired,
+// for when it was rejected.
+func (w *WiFire) renewToken(ctx context.Context) (string, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.tokenExpires = time.Time{}
+
+	if err := w.ensureValidToken(ctx); err != nil {
+		return "", err
+	}
+
+	return w.token, nil
+}
+
+// cognito posts the body to the Cognito identity provider action and decodes
+// the reply into response.
+func (w *WiFire) cognito(ctx context.Context, action string, body, response any) error {
+	b, err := json.Marshal(body)
 	if err != nil {
 		return err
 	}
 
-	defer r.Body.Close()
+	r, err := w.doWithRetry(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", w.config.cognitoURL, bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
 
-	var auth requestTokenResponse
+		req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		req.Header.Set("X-Amz-Target", "AWSCognitoIdentityProviderService."+action)
 
-	if err := json.NewDecoder(r.Body).Decode(&auth); err != nil {
-		return err
+		return req, nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+
+		return fmt.Errorf("%w: %w", ErrCognitoUnavailable, err)
 	}
 
-	w.token = auth.AuthenticationResult.IDToken
-	w.tokenExpires = t0.Add(time.Second * time.Duration(auth.AuthenticationResult.ExpiresIn))
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		var e cognitoError
 
-	return nil
+		_ = json.NewDecoder(r.Body).Decode(&e)
+
+		switch {
+		case e.Type == "NotAuthorizedException", e.Type == "UserNotFoundException":
+			return fmt.Errorf("%w: %s", ErrInvalidCredentials, e.Message)
+		case r.StatusCode >= http.StatusInternalServerError, e.Type == "TooManyRequestsException":
+			return fmt.Errorf("%w: %s %s", ErrCognitoUnavailable, r.Status, e.Message)
+		default:
+			return fmt.Errorf("%s failed: %s %s %s", action, r.Status, e.Type, e.Message)
+		}
+	}
+
+	return json.NewDecoder(r.Body).Decode(response)
 }
@@ -0,0 +1,398 @@
+package wifire
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testJWT returns an unsigned JWT with the exp claim.
+func testJWT(exp time.Time) string {
+	enc := base64.RawURLEncoding.EncodeToString
+
+	return enc([]byte(`{"alg":"none"}`)) + "." +
+		enc([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix()))) + "." +
+		enc([]byte("signature"))
+}
+
+// fakeAPI is an httptest server standing in for both Cognito and the WiFire
+// API. It records each request as the Cognito action and auth flow, or as
+// the method and path. The user data and signed MQTT URL are always the same.
+type fakeAPI struct {
+	*httptest.Server
+
+	expiresIn int    // seconds until the issued ID tokens expire, the default is an hour
+	authError string // Cognito error type returned by InitiateAuth
+	users     []int  // status codes returned by /prod/users/self in turn, then 200
+
+	mutex sync.Mutex
+	calls []string
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	t.Helper()
+
+	f := &fakeAPI{}
+	f.Server = httptest.NewServer(f)
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+// options returns the New options to log in to the fakeAPI with a password.
+func (f *fakeAPI) options() []func(*WiFire) {
+	return []func(*WiFire){
+		URLs(f.URL, f.URL+"/"),
+		Credentials("user", "password"),
+		RetryPolicy(3, time.Millisecond),
+	}
+}
+
+// requests returns the recorded requests, in order.
+func (f *fakeAPI) requests() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return slices.Clone(f.calls)
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		var body requestTokenBody
+
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		action := strings.TrimPrefix(target, "AWSCognitoIdentityProviderService.")
+		f.calls = append(f.calls, strings.TrimSpace(action+" "+body.AuthFlow))
+
+		if f.authError != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(cognitoError{Type: f.authError, Message: "rejected"})
+
+			return
+		}
+
+		expiresIn := f.expiresIn
+		if expiresIn == 0 {
+			expiresIn = 3600
+		}
+
+		_ = json.NewEncoder(w).Encode(requestTokenResponse{
+			AuthenticationResult: authenticationResult{
+				AccessToken:  "access",
+				ExpiresIn:    expiresIn,
+				IDToken:      testJWT(time.Now().Add(time.Duration(expiresIn) * time.Second)),
+				RefreshToken: "refresh",
+			},
+		})
+
+		return
+	}
+
+	f.calls = append(f.calls, r.Method+" "+r.URL.Path)
+
+	if r.URL.Path == "/prod/mqtt-connections" {
+		_ = json.NewEncoder(w).Encode(getMQTTResponse{
+			ExpirationSeconds: 3600,
+			SignedURL:         "wss://broker.example/mqtt?X-Amz-Signature=signature",
+		})
+
+		return
+	}
+
+	if len(f.users) > 0 {
+		code := f.users[0]
+		f.users = f.users[1:]
+
+		if code != http.StatusOK {
+			w.WriteHeader(code)
+			return
+		}
+	}
+
+	_ = json.NewEncoder(w).Encode(getUserDataResponse{
+		UserID: "user-id",
+		Things: []thing{{Name: "grill", GrillModel: GrillModel{Name: "Ironwood 885"}}},
+	})
+}
+
+// memTokenStore is a TokenStore in memory.
+type memTokenStore struct {
+	mutex        sync.Mutex
+	idToken      string
+	refreshToken string
+	saves        int
+}
+
+func (m *memTokenStore) Load() (idToken, refreshToken string, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.idToken, m.refreshToken, nil
+}
+
+func (m *memTokenStore) Save(idToken, refreshToken string, _ time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.idToken, m.refreshToken = idToken, refreshToken
+	m.saves++
+
+	return nil
+}
+
+func TestCloseWithoutAccessToken(t *testing.T) {
+	store := &memTokenStore{}
+
+	w, err := New(
+		IDToken(testJWT(time.Now().Add(time.Hour)), "refresh"),
+		TokenStorage(store),
+		URLs("http://127.0.0.1:1", "http://127.0.0.1:1"), // never called
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.token != "" || w.refreshToken != "" || !w.tokenExpires.IsZero() {
+		t.Errorf("tokens not cleared: %q %q %s", w.token, w.refreshToken, w.tokenExpires)
+	}
+
+	if store.saves != 1 || store.idToken != "" || store.refreshToken != "" {
+		t.Errorf("store not wiped: %d saves, %q %q", store.saves, store.idToken, store.refreshToken)
+	}
+}
+
+func TestCloseFromConnectionChange(t *testing.T) {
+	g, _ := newFakeGrill(t)
+	w := g.wifire
+
+	closed := make(chan error, 1)
+
+	g.OnConnectionChange(func(s ConnState) {
+		if s == Disconnected {
+			w.NewGrill("other") // takes the WiFire mutex
+			closed <- w.Close()
+		}
+	})
+
+	done := make(chan error)
+
+	go func() { done <- w.Close() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close deadlocked in the OnConnectionChange func")
+	}
+
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTokenRefreshBeforeExpiry(t *testing.T) {
+	api := newFakeAPI(t)
+	api.expiresIn = 60 // within the refresh skew, so it is always near expiry
+
+	w, err := New(api.options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.UserData(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"InitiateAuth USER_PASSWORD_AUTH",
+		"InitiateAuth REFRESH_TOKEN_AUTH",
+		"GET /prod/users/self",
+	}
+
+	if got := api.requests(); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestNewContextCanceled(t *testing.T) {
+	api := newFakeAPI(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := NewContext(ctx, api.options()...); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestUserDataContextCanceled(t *testing.T) {
+	api := newFakeAPI(t)
+
+	w, err := New(api.options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := w.UserDataContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+
+	if got := api.requests(); slices.Contains(got, "GET /prod/users/self") {
+		t.Errorf("request sent with a canceled context: %q", got)
+	}
+}
+
+// countingTransport is an http.RoundTripper counting the requests it sends.
+type countingTransport struct {
+	mutex sync.Mutex
+	n     int
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.mutex.Lock()
+	c.n++
+	c.mutex.Unlock()
+
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestHTTPClient(t *testing.T) {
+	api := newFakeAPI(t)
+	rt := &countingTransport{}
+
+	w, err := New(append(api.options(), HTTPClient(&http.Client{Transport: rt}))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := w.UserData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.UserID != "user-id" || len(data.Things) != 1 || data.Things[0].Name != "grill" {
+		t.Errorf("got %+v", data)
+	}
+
+	if rt.n != 2 { // the login and the user data
+		t.Errorf("%d requests through the client, want 2", rt.n)
+	}
+}
+
+func TestInvalidCredentials(t *testing.T) {
+	for _, typ := range []string{"NotAuthorizedException", "UserNotFoundException"} {
+		api := newFakeAPI(t)
+		api.authError = typ
+
+		if _, err := New(api.options()...); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: got %v, want ErrInvalidCredentials", typ, err)
+		}
+	}
+}
+
+func TestCognitoUnavailable(t *testing.T) {
+	api := newFakeAPI(t)
+	opts := api.options()
+	api.Close() // connections are refused
+
+	_, err := New(opts...)
+	if !errors.Is(err, ErrCognitoUnavailable) || errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("got %v, want ErrCognitoUnavailable", err)
+	}
+}
+
+func TestRegion(t *testing.T) {
+	store := &memTokenStore{idToken: testJWT(time.Now().Add(time.Hour))} // skips the login
+
+	w, err := New(Region("eu-west-1"), TokenStorage(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "https://cognito-idp.eu-west-1.amazonaws.com/"; w.config.cognitoURL != want {
+		t.Errorf("got %q, want %q", w.config.cognitoURL, want)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for name, opts := range map[string][]func(*WiFire){
+		"empty region":     {Region("")},
+		"invalid base URL": {URLs("not a url", "https://cognito.example.com/")},
+		"invalid cognito":  {URLs("https://api.example.com", "://")},
+	} {
+		if _, err := New(opts...); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestRenewTokenOnUnauthorized(t *testing.T) {
+	for _, code := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		api := newFakeAPI(t)
+		api.users = []int{code}
+
+		w, err := New(api.options()...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := w.UserData()
+		if err != nil {
+			t.Fatalf("%d: %s", code, err)
+		}
+
+		if data.UserID != "user-id" {
+			t.Errorf("%d: user %q", code, data.UserID)
+		}
+
+		want := []string{
+			"InitiateAuth USER_PASSWORD_AUTH",
+			"GET /prod/users/self",
+			"InitiateAuth REFRESH_TOKEN_AUTH",
+			"GET /prod/users/self",
+		}
+
+		if got := api.requests(); !slices.Equal(got, want) {
+			t.Errorf("%d: got %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestUnauthorizedAfterRenewal(t *testing.T) {
+	api := newFakeAPI(t)
+	api.users = []int{http.StatusUnauthorized, http.StatusUnauthorized}
+
+	w, err := New(api.options()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.UserData(); err == nil {
+		t.Error("no error after the renewed token was rejected")
+	}
+
+	if n := len(api.requests()); n != 4 {
+		t.Errorf("%d requests, want the token renewed only once: %q", n, api.requests())
+	}
+}